@@ -0,0 +1,138 @@
+// Package alu implements the SM83 arithmetic and logic operations as pure
+// functions. Each takes its operands and, where an operation depends on or
+// keeps existing flags, the current F register. It returns the result
+// together with the new F.
+package alu
+
+// Flag bits, in the same layout as the F register
+const (
+	FlagZ = 0x80 // Zero flag
+	FlagN = 0x40 // Negative flag
+	FlagH = 0x20 // Half-carry flag
+	FlagC = 0x10 // Carry flag
+)
+
+// flags packs Z, N, H and C into an F register value
+func flags(z, n, h, c bool) byte {
+	var f byte
+	if z {
+		f |= FlagZ
+	}
+	if n {
+		f |= FlagN
+	}
+	if h {
+		f |= FlagH
+	}
+	if c {
+		f |= FlagC
+	}
+	return f
+}
+
+// carryIn returns the carry flag of f as 0 or 1
+func carryIn(f byte) byte {
+	return f & FlagC >> 4
+}
+
+// Add8 is ADD: Z, N cleared, H on carry out of bit 3, C on carry out of bit 7
+func Add8(a, b byte) (byte, byte) {
+	return Adc8(a, b, 0)
+}
+
+// Adc8 is ADC: ADD with the carry flag of f added in
+func Adc8(a, b, f byte) (byte, byte) {
+	carry := carryIn(f)
+	result := uint16(a) + uint16(b) + uint16(carry)
+	halfCarry := a&0x0F+b&0x0F+carry > 0x0F
+	return byte(result), flags(byte(result) == 0, false, halfCarry, result > 0xFF)
+}
+
+// Sub8 is SUB and CP: Z, N set, H on borrow from bit 4, C on borrow
+func Sub8(a, b byte) (byte, byte) {
+	return Sbc8(a, b, 0)
+}
+
+// Sbc8 is SBC: SUB with the carry flag of f subtracted as well
+func Sbc8(a, b, f byte) (byte, byte) {
+	carry := carryIn(f)
+	halfBorrow := int(a&0x0F)-int(b&0x0F)-int(carry) < 0
+	borrow := int(a)-int(b)-int(carry) < 0
+	result := a - b - carry
+	return result, flags(result == 0, true, halfBorrow, borrow)
+}
+
+// And8 is AND: Z, N cleared, H set, C cleared
+func And8(a, b byte) (byte, byte) {
+	return a & b, flags(a&b == 0, false, true, false)
+}
+
+// Or8 is OR: Z, N, H and C cleared
+func Or8(a, b byte) (byte, byte) {
+	return a | b, flags(a|b == 0, false, false, false)
+}
+
+// Xor8 is XOR: Z, N, H and C cleared
+func Xor8(a, b byte) (byte, byte) {
+	return a ^ b, flags(a^b == 0, false, false, false)
+}
+
+// Inc8 is INC: Z, N cleared, H on carry out of bit 3, C kept from f
+func Inc8(v, f byte) (byte, byte) {
+	result := v + 1
+	return result, flags(result == 0, false, v&0x0F == 0x0F, f&FlagC != 0)
+}
+
+// Dec8 is DEC: Z, N set, H on borrow from bit 4, C kept from f
+func Dec8(v, f byte) (byte, byte) {
+	result := v - 1
+	return result, flags(result == 0, true, v&0x0F == 0, f&FlagC != 0)
+}
+
+// Add16 is ADD HL: Z kept from f, N cleared, H on carry out of bit 11, C on
+// carry out of bit 15
+func Add16(a, b uint16, f byte) (uint16, byte) {
+	result := uint32(a) + uint32(b)
+	halfCarry := a&0x0FFF+b&0x0FFF > 0x0FFF
+	return uint16(result), flags(f&FlagZ != 0, false, halfCarry, result > 0xFFFF)
+}
+
+// AddSP is SP plus a signed offset for ADD SP,e8 and LD HL,SP+e8. Unusually,
+// H and C come from an unsigned add of the low byte of SP and the offset, and
+// Z is always cleared.
+func AddSP(sp uint16, offset int8) (uint16, byte) {
+	low := byte(offset)
+	halfCarry := byte(sp)&0x0F+low&0x0F > 0x0F
+	carry := uint16(byte(sp))+uint16(low) > 0xFF
+	return sp + uint16(offset), flags(false, false, halfCarry, carry)
+}
+
+// Daa adjusts a back to packed BCD after an addition or subtraction, using
+// the N, H and C flags the operation left in f
+func Daa(a, f byte) (byte, byte) {
+	var adjust byte
+	carry := f&FlagC != 0
+	subtract := f&FlagN != 0
+
+	if subtract {
+		// After a subtraction only the recorded borrows need undoing
+		if f&FlagH != 0 {
+			adjust |= 0x06
+		}
+		if carry {
+			adjust |= 0x60
+		}
+		a -= adjust
+	} else {
+		// After an addition also fix digits that went past 9
+		if f&FlagH != 0 || a&0x0F > 0x09 {
+			adjust |= 0x06
+		}
+		if carry || a > 0x99 {
+			adjust |= 0x60
+			carry = true
+		}
+		a += adjust
+	}
+	return a, flags(a == 0, subtract, false, carry)
+}
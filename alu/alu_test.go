@@ -0,0 +1,206 @@
+package alu
+
+import "testing"
+
+// The reference model below works on wide integers and derives the half
+// carry from the carry into bit 4 (a^b^result), independently of the nibble
+// sums the package uses.
+
+func refFlags(result int, n, h, c bool) byte {
+	return flags(result&0xFF == 0, n, h, c)
+}
+
+func TestAddAdcExhaustive(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			for carry := 0; carry < 2; carry++ {
+				r := a + b + carry
+				wantF := refFlags(r, false, (a^b^r)&0x10 != 0, r > 0xFF)
+
+				gotA, gotF := Adc8(byte(a), byte(b), byte(carry)<<4)
+				if gotA != byte(r) || gotF != wantF {
+					t.Fatalf("Adc8(%02X, %02X, C=%d) = %02X/%02X, want %02X/%02X", a, b, carry, gotA, gotF, byte(r), wantF)
+				}
+				if carry == 0 {
+					gotA, gotF = Add8(byte(a), byte(b))
+					if gotA != byte(r) || gotF != wantF {
+						t.Fatalf("Add8(%02X, %02X) = %02X/%02X, want %02X/%02X", a, b, gotA, gotF, byte(r), wantF)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSubSbcExhaustive(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			for carry := 0; carry < 2; carry++ {
+				r := a - b - carry
+				wantF := refFlags(r, true, (a^b^r)&0x10 != 0, r < 0)
+
+				gotA, gotF := Sbc8(byte(a), byte(b), byte(carry)<<4)
+				if gotA != byte(r) || gotF != wantF {
+					t.Fatalf("Sbc8(%02X, %02X, C=%d) = %02X/%02X, want %02X/%02X", a, b, carry, gotA, gotF, byte(r), wantF)
+				}
+				if carry == 0 {
+					gotA, gotF = Sub8(byte(a), byte(b))
+					if gotA != byte(r) || gotF != wantF {
+						t.Fatalf("Sub8(%02X, %02X) = %02X/%02X, want %02X/%02X", a, b, gotA, gotF, byte(r), wantF)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLogicExhaustive(t *testing.T) {
+	ops := []struct {
+		name string
+		fn   func(a, b byte) (byte, byte)
+		ref  func(a, b int) int
+		h    bool
+	}{
+		{"And8", And8, func(a, b int) int { return a & b }, true},
+		{"Or8", Or8, func(a, b int) int { return a | b }, false},
+		{"Xor8", Xor8, func(a, b int) int { return a ^ b }, false},
+	}
+	for _, op := range ops {
+		for a := 0; a < 256; a++ {
+			for b := 0; b < 256; b++ {
+				r := op.ref(a, b)
+				wantF := refFlags(r, false, op.h, false)
+				gotA, gotF := op.fn(byte(a), byte(b))
+				if gotA != byte(r) || gotF != wantF {
+					t.Fatalf("%s(%02X, %02X) = %02X/%02X, want %02X/%02X", op.name, a, b, gotA, gotF, byte(r), wantF)
+				}
+			}
+		}
+	}
+}
+
+func TestIncDecExhaustive(t *testing.T) {
+	for v := 0; v < 256; v++ {
+		for f := 0; f < 256; f += 0x10 {
+			carry := f&FlagC != 0
+
+			r := v + 1
+			wantF := refFlags(r, false, (v^1^r)&0x10 != 0, carry)
+			if got, gotF := Inc8(byte(v), byte(f)); got != byte(r) || gotF != wantF {
+				t.Fatalf("Inc8(%02X, F=%02X) = %02X/%02X, want %02X/%02X", v, f, got, gotF, byte(r), wantF)
+			}
+
+			r = v - 1
+			wantF = refFlags(r, true, (v^1^r)&0x10 != 0, carry)
+			if got, gotF := Dec8(byte(v), byte(f)); got != byte(r) || gotF != wantF {
+				t.Fatalf("Dec8(%02X, F=%02X) = %02X/%02X, want %02X/%02X", v, f, got, gotF, byte(r), wantF)
+			}
+		}
+	}
+}
+
+// refDaa is the adjustment as usually documented: fix the high digit first,
+// judged on the unadjusted value, then the low digit
+func refDaa(a int, n, h, c bool) (int, bool) {
+	if !n {
+		if c || a > 0x99 {
+			a += 0x60
+			c = true
+		}
+		if h || a&0x0F > 0x09 {
+			a += 0x06
+		}
+	} else {
+		if c {
+			a -= 0x60
+		}
+		if h {
+			a -= 0x06
+		}
+	}
+	return a & 0xFF, c
+}
+
+func TestDaaExhaustive(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for f := 0; f < 256; f += 0x10 {
+			n, h, c := f&FlagN != 0, f&FlagH != 0, f&FlagC != 0
+			r, carry := refDaa(a, n, h, c)
+			wantF := refFlags(r, n, false, carry)
+			if got, gotF := Daa(byte(a), byte(f)); got != byte(r) || gotF != wantF {
+				t.Fatalf("Daa(%02X, F=%02X) = %02X/%02X, want %02X/%02X", a, f, got, gotF, r, wantF)
+			}
+		}
+	}
+}
+
+func TestDaaAfterBCDArithmetic(t *testing.T) {
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			bx, by := byte(x/10<<4|x%10), byte(y/10<<4|y%10)
+
+			sum, f := Add8(bx, by)
+			got, gotF := Daa(sum, f)
+			want := (x + y) % 100
+			if got != byte(want/10<<4|want%10) || (gotF&FlagC != 0) != (x+y > 99) {
+				t.Fatalf("%d + %d: DAA gave %02X/%02X", x, y, got, gotF)
+			}
+
+			diff, f := Sub8(bx, by)
+			got, gotF = Daa(diff, f)
+			want = (x - y + 100) % 100
+			if got != byte(want/10<<4|want%10) || (gotF&FlagC != 0) != (x < y) {
+				t.Fatalf("%d - %d: DAA gave %02X/%02X", x, y, got, gotF)
+			}
+		}
+	}
+}
+
+func TestAdd16(t *testing.T) {
+	tests := []struct {
+		a, b  uint16
+		f     byte
+		want  uint16
+		wantF byte
+	}{
+		{0x0000, 0x0000, 0x00, 0x0000, 0x00},
+		{0x0FFF, 0x0001, 0x00, 0x1000, FlagH},
+		{0x00FF, 0x0001, 0x00, 0x0100, 0x00}, // No flag from bit 7
+		{0xFFFF, 0x0001, 0x00, 0x0000, FlagH | FlagC},
+		{0x8000, 0x8000, 0x00, 0x0000, FlagC},
+		{0x1234, 0x1111, FlagZ | FlagN | FlagH | FlagC, 0x2345, FlagZ}, // Z kept, N cleared
+		{0xF000, 0x1000, FlagZ, 0x0000, FlagZ | FlagC},
+	}
+	for _, tt := range tests {
+		got, gotF := Add16(tt.a, tt.b, tt.f)
+		if got != tt.want || gotF != tt.wantF {
+			t.Errorf("Add16(%04X, %04X, F=%02X) = %04X/%02X, want %04X/%02X", tt.a, tt.b, tt.f, got, gotF, tt.want, tt.wantF)
+		}
+	}
+}
+
+func TestAddSP(t *testing.T) {
+	tests := []struct {
+		sp     uint16
+		offset int8
+		want   uint16
+		wantF  byte
+	}{
+		{0x0000, 0, 0x0000, 0x00}, // Z is never set
+		{0x000F, 1, 0x0010, FlagH},
+		{0x00FF, 1, 0x0100, FlagH | FlagC},
+		{0xFFFF, 1, 0x0000, FlagH | FlagC},
+		{0x0FFF, 1, 0x1000, FlagH | FlagC}, // Flags come from the low byte only
+		{0x0000, -1, 0xFFFF, 0x00},
+		{0x0001, -1, 0x0000, FlagH | FlagC},
+		{0xD000, -128, 0xCF80, 0x00},
+		{0xD080, -128, 0xD000, FlagC},
+		{0xFFF8, 127, 0x0077, FlagH | FlagC},
+	}
+	for _, tt := range tests {
+		got, gotF := AddSP(tt.sp, tt.offset)
+		if got != tt.want || gotF != tt.wantF {
+			t.Errorf("AddSP(%04X, %d) = %04X/%02X, want %04X/%02X", tt.sp, tt.offset, got, gotF, tt.want, tt.wantF)
+		}
+	}
+}
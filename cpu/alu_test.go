@@ -0,0 +1,123 @@
+package cpu
+
+import "testing"
+
+func TestALUFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(cpu *CPU, value byte)
+		a, v  byte
+		f     byte // F before the operation
+		wantA byte
+		wantF byte
+	}{
+		// ADD: H on carry out of bit 3, C out of bit 7
+		{"ADD no carry", (*CPU).Add, 0x12, 0x34, 0x00, 0x46, 0},
+		{"ADD half carry", (*CPU).Add, 0x0F, 0x01, 0x00, 0x10, FlagH},
+		{"ADD carry", (*CPU).Add, 0xF0, 0x20, 0x00, 0x10, FlagC},
+		{"ADD zero", (*CPU).Add, 0xFF, 0x01, 0x00, 0x00, FlagZ | FlagH | FlagC},
+		{"ADD clears N", (*CPU).Add, 0x01, 0x01, FlagN, 0x02, 0},
+
+		// SUB: N set, H on borrow from bit 4, C on borrow
+		{"SUB no borrow", (*CPU).Sub, 0x46, 0x34, 0x00, 0x12, FlagN},
+		{"SUB half borrow", (*CPU).Sub, 0x10, 0x01, 0x00, 0x0F, FlagN | FlagH},
+		{"SUB borrow", (*CPU).Sub, 0x10, 0x20, 0x00, 0xF0, FlagN | FlagC},
+		{"SUB zero", (*CPU).Sub, 0x42, 0x42, 0x00, 0x00, FlagZ | FlagN},
+
+		// AND sets H, OR and XOR clear it
+		{"AND", (*CPU).And, 0xF0, 0x3C, FlagN | FlagC, 0x30, FlagH},
+		{"AND zero", (*CPU).And, 0xF0, 0x0F, 0x00, 0x00, FlagZ | FlagH},
+		{"OR", (*CPU).Or, 0xF0, 0x0F, FlagH | FlagC, 0xFF, 0},
+		{"XOR zero", (*CPU).Xor, 0x5A, 0x5A, FlagH | FlagC, 0x00, FlagZ},
+	}
+	for _, tt := range tests {
+		cpu := &CPU{A: tt.a, F: tt.f}
+		tt.op(cpu, tt.v)
+		if cpu.A != tt.wantA || cpu.F != tt.wantF {
+			t.Errorf("%s: A=%02X F=%02X, want A=%02X F=%02X", tt.name, cpu.A, cpu.F, tt.wantA, tt.wantF)
+		}
+	}
+}
+
+func TestIncDecFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(cpu *CPU, value byte) byte
+		v     byte
+		f     byte
+		want  byte
+		wantF byte
+	}{
+		{"INC", (*CPU).Inc, 0x41, 0x00, 0x42, 0},
+		{"INC half carry", (*CPU).Inc, 0x0F, 0x00, 0x10, FlagH},
+		{"INC wraps to zero", (*CPU).Inc, 0xFF, 0x00, 0x00, FlagZ | FlagH}, // No carry out
+		{"INC keeps C", (*CPU).Inc, 0xFF, FlagC, 0x00, FlagZ | FlagH | FlagC},
+		{"INC clears N", (*CPU).Inc, 0x00, FlagN | FlagC, 0x01, FlagC},
+		{"DEC", (*CPU).Dec, 0x42, 0x00, 0x41, FlagN},
+		{"DEC half borrow", (*CPU).Dec, 0x10, 0x00, 0x0F, FlagN | FlagH},
+		{"DEC to zero", (*CPU).Dec, 0x01, 0x00, 0x00, FlagZ | FlagN},
+		{"DEC wraps", (*CPU).Dec, 0x00, 0x00, 0xFF, FlagN | FlagH}, // No borrow out
+		{"DEC keeps C", (*CPU).Dec, 0x00, FlagC, 0xFF, FlagN | FlagH | FlagC},
+	}
+	for _, tt := range tests {
+		cpu := &CPU{F: tt.f}
+		got := tt.op(cpu, tt.v)
+		if got != tt.want || cpu.F != tt.wantF {
+			t.Errorf("%s: %02X F=%02X, want %02X F=%02X", tt.name, got, cpu.F, tt.want, tt.wantF)
+		}
+	}
+}
+
+// TestCarryInExhaustive checks ADC, SBC and CP over every accumulator,
+// operand and carry input. H and C include the carry in, and CP only sets
+// flags.
+func TestCarryInExhaustive(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for v := 0; v < 256; v++ {
+			for carry := 0; carry < 2; carry++ {
+				f := byte(carry) * FlagC
+
+				cpu := &CPU{A: byte(a), F: f}
+				cpu.Adc(byte(v))
+				r := a + v + carry
+				want := wideFlags(r, false, (a&0x0F)+(v&0x0F)+carry > 0x0F, r > 0xFF)
+				if cpu.A != byte(r) || cpu.F != want {
+					t.Fatalf("ADC %02X,%02X C=%d: A=%02X F=%02X, want A=%02X F=%02X", a, v, carry, cpu.A, cpu.F, byte(r), want)
+				}
+
+				cpu = &CPU{A: byte(a), F: f}
+				cpu.Sbc(byte(v))
+				r = a - v - carry
+				want = wideFlags(r, true, (a&0x0F)-(v&0x0F)-carry < 0, r < 0)
+				if cpu.A != byte(r) || cpu.F != want {
+					t.Fatalf("SBC %02X,%02X C=%d: A=%02X F=%02X, want A=%02X F=%02X", a, v, carry, cpu.A, cpu.F, byte(r), want)
+				}
+
+				cpu = &CPU{A: byte(a), F: f}
+				cpu.Cp(byte(v))
+				r = a - v
+				want = wideFlags(r, true, a&0x0F < v&0x0F, r < 0)
+				if cpu.A != byte(a) || cpu.F != want {
+					t.Fatalf("CP %02X,%02X C=%d: A=%02X F=%02X, want A=%02X F=%02X", a, v, carry, cpu.A, cpu.F, a, want)
+				}
+			}
+		}
+	}
+}
+
+func wideFlags(result int, n, h, c bool) byte {
+	var f byte
+	if result&0xFF == 0 {
+		f |= FlagZ
+	}
+	if n {
+		f |= FlagN
+	}
+	if h {
+		f |= FlagH
+	}
+	if c {
+		f |= FlagC
+	}
+	return f
+}
@@ -0,0 +1,90 @@
+package cpu
+
+import "clockworkgnome/memory"
+
+// prefixCB runs a 0xCB-prefixed instruction. The second byte encodes the
+// operation in bits 6-7, a sub-operation or bit number in bits 3-5 and the
+// register in bits 0-2.
+func prefixCB(cpu *CPU, memory *memory.Memory) {
+	op := &cbOpcodes[cpu.readD8(memory)]
+	op.run(cpu, memory)
+	cpu.Cycles += op.Cycles - baseOpcodes[0xCB].Cycles // CB timings include the prefix
+}
+
+// shiftOp computes a rotate, shift or SWAP, returning the result and the bit
+// shifted out into carry
+type shiftOp func(cpu *CPU, value byte) (result, carry byte)
+
+var shiftOps = [8]shiftOp{
+	func(cpu *CPU, v byte) (byte, byte) { return v<<1 | v>>7, v >> 7 },                // RLC
+	func(cpu *CPU, v byte) (byte, byte) { return v>>1 | v<<7, v & 0x01 },              // RRC
+	func(cpu *CPU, v byte) (byte, byte) { return v<<1 | cpu.carryBit(), v >> 7 },      // RL
+	func(cpu *CPU, v byte) (byte, byte) { return v>>1 | cpu.carryBit()<<7, v & 0x01 }, // RR
+	func(cpu *CPU, v byte) (byte, byte) { return v << 1, v >> 7 },                     // SLA
+	func(cpu *CPU, v byte) (byte, byte) { return v>>1 | v&0x80, v & 0x01 },            // SRA, bit 7 is kept
+	func(cpu *CPU, v byte) (byte, byte) { return v<<4 | v>>4, 0 },                     // SWAP
+	func(cpu *CPU, v byte) (byte, byte) { return v >> 1, v & 0x01 },                   // SRL
+}
+
+// registerCBInstructions fills in the handlers of the CB table
+func registerCBInstructions() {
+	for i := range cbOpcodes {
+		op := byte(i)
+		reg, n := op&0x07, op>>3&0x07
+		switch op >> 6 {
+		case 0:
+			cbOpcodes[i].run = shift(shiftOps[n], reg) // Rotates, shifts and SWAP
+		case 1:
+			cbOpcodes[i].run = testBit(n, reg) // BIT n, r
+		case 2:
+			cbOpcodes[i].run = resetBit(n, reg) // RES n, r
+		case 3:
+			cbOpcodes[i].run = setBit(n, reg) // SET n, r
+		}
+	}
+}
+
+// shift applies a rotate or shift, setting Z and C from the result
+func shift(fn shiftOp, reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		result, carry := fn(cpu, cpu.getRegister(reg, memory))
+		cpu.setRegister(reg, result, memory)
+		cpu.setFlags(result == 0, false, false, carry != 0)
+	}
+}
+
+// testBit sets Z when bit n is clear, clears N, sets H and keeps C
+func testBit(n, reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		value := cpu.getRegister(reg, memory)
+		cpu.setFlags(value&(1<<n) == 0, false, true, cpu.F&FlagC != 0)
+	}
+}
+
+func resetBit(n, reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(reg, cpu.getRegister(reg, memory)&^(1<<n), memory)
+	}
+}
+
+func setBit(n, reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(reg, cpu.getRegister(reg, memory)|(1<<n), memory)
+	}
+}
+
+// cbCycles is 8 for register operands, or hlCycles when operating on (HL)
+func cbCycles(reg byte, hlCycles int) int {
+	if reg == regHL {
+		return hlCycles
+	}
+	return 8
+}
+
+// carryBit returns the carry flag as 0 or 1
+func (cpu *CPU) carryBit() byte {
+	if cpu.F&FlagC != 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+//go:build checked
+
+package cpu
+
+import "fmt"
+
+// Checked reports whether emulation invariants are asserted (-tags checked)
+const Checked = true
+
+// Longest base instruction (CALL a16), anything above is a core bug
+const maxInstructionCycles = 24
+
+// checkInvariants panics with context when an instruction leaves the CPU in
+// a state real hardware can never reach
+func (cpu *CPU) checkInvariants(opcode byte, pc uint16, cycles int) {
+	fail := func(format string, args ...any) {
+		panic(fmt.Sprintf("checked: opcode %02X at %04X: %s (AF=%02X%02X BC=%02X%02X DE=%02X%02X HL=%02X%02X SP=%04X PC=%04X)",
+			opcode, pc, fmt.Sprintf(format, args...),
+			cpu.A, cpu.F, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L, cpu.SP, cpu.PC))
+	}
+
+	if cpu.F&^flagMask != 0 {
+		fail("flag register low nibble is %X", cpu.F&^flagMask)
+	}
+	if cycles < 0 || cycles > maxInstructionCycles || cycles%4 != 0 {
+		fail("instruction took %d cycles", cycles)
+	}
+}
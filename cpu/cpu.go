@@ -1,268 +1,326 @@
 package cpu
 
 import (
+	"clockworkgnome/alu"
 	"clockworkgnome/memory"
-	"fmt"
+	"io"
 )
 
 // Define the CPU structure with registers and flags
 type CPU struct {
-	A, F   byte   // Accumulator and Flags
-	B, C   byte   // Register B and C
-	D, E   byte   // Register D and E
-	H, L   byte   // Register H and L
-	SP     uint16 // Stack Pointer
-	PC     uint16 // Program Counter
-	Cycles int    // Cycle counter
-	IM     byte   // Interrupt Master Flag
-	Timer  int    // Timer for emulation
+	A, F    byte   // Accumulator and Flags, the low nibble of F always reads zero
+	B, C    byte   // Register B and C
+	D, E    byte   // Register D and E
+	H, L    byte   // Register H and L
+	SP      uint16 // Stack Pointer
+	PC      uint16 // Program Counter
+	Cycles  int    // Cycle counter
+	IME     bool   // Interrupt Master Enable
+	Halted  bool   // Set by HALT until an interrupt is pending
+	Stopped bool   // Set by STOP until a joypad interrupt is requested
+	Locked  bool   // Set by an illegal opcode, only a reset recovers
+	Timer   int    // Timer for emulation
+	Model   Model  // Hardware revision being emulated
+
+	haltBug  bool // HALT with IME clear and an interrupt pending, PC fails to advance once
+	branched bool // Set by a conditional instruction that took its branch
+	eiDelay  bool // Set by EI, IME turns on once the following instruction completes
+
+	doubleSpeed bool // CGB double-speed mode
+	switching   int  // Cycles left of the pause after a speed switch
+
+	watchdog *Watchdog // Reports runaway emulation, if set
+	log      io.Writer // Receives diagnostics such as unknown opcodes, if set
+
+	illegalMode IllegalOpcodeMode // What undefined opcodes do
+	traceFunc   func(TraceEvent)  // Called before each instruction, if set
+	err         error             // Why the CPU stopped, if it did
+	onError     func(error)       // Receives recoverable errors such as unknown opcodes, if set
 }
 
 // Flags
 const (
-	FlagZ = 0x80 // Zero flag
-	FlagN = 0x40 // Negative flag
-	FlagH = 0x20 // Half-carry flag
-	FlagC = 0x10 // Carry flag
+	FlagZ = alu.FlagZ // Zero flag
+	FlagN = alu.FlagN // Negative flag
+	FlagH = alu.FlagH // Half-carry flag
+	FlagC = alu.FlagC // Carry flag
+
+	flagMask = FlagZ | FlagN | FlagH | FlagC // Bits 0-3 of F do not exist
+)
+
+// Hardware registers the CPU touches directly, aliased here because the
+// instruction handlers name their bus parameter "memory"
+const (
+	addrIE   = memory.IEAddr
+	addrIF   = memory.IFAddr
+	addrKEY1 = memory.KEY1Addr
+
+	interruptJoypad = memory.InterruptJoypad
 )
 
-// Initialize CPU
-func NewCPU() *CPU {
+// NewCPU returns a CPU in the state the model's boot ROM hands over to the
+// cartridge. Use Model.InitIO to set up the matching I/O registers.
+func NewCPU(model Model) *CPU {
+	r := postBootRegisters[model]
 	return &CPU{
-		A:      0,
-		F:      0,
-		B:      0,
-		C:      0,
-		D:      0,
-		E:      0,
-		H:      0,
-		L:      0,
+		A: r[0], F: r[1],
+		B: r[2], C: r[3],
+		D: r[4], E: r[5],
+		H: r[6], L: r[7],
 		SP:     0xFFFE, // Initial Stack Pointer
 		PC:     0x0100, // Starting address for Game Boy
 		Cycles: 0,
-		IM:     0, // Interrupt Master Flag
-		Timer:  0, // Initialize Timer
+		IME:    false, // Interrupts disabled until EI
+		Timer:  0,     // Initialize Timer
+		Model:  model,
 	}
 }
 
-// Execute method for fetching and executing instructions
-func (cpu *CPU) Execute(memory memory.Memory) {
-	opcode := memory.Read(cpu.PC) // Fetch the opcode
-	cpu.PC++
+// SetLogOutput sends diagnostics to w, or discards them when w is nil
+func (cpu *CPU) SetLogOutput(w io.Writer) {
+	cpu.log = w
+}
 
-	switch opcode {
-	// Jump Instructions
-	case 0xC3: // JP a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		cpu.PC = addr
-		cpu.Cycles += 16
-
-	case 0xC2: // JP NZ, a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		if cpu.F&FlagZ == 0 { // Jump if Zero flag is clear
-			cpu.PC = addr
-			cpu.Cycles += 16
-		} else {
-			cpu.PC += 2
-			cpu.Cycles += 12
+// Execute method for fetching and executing instructions
+func (cpu *CPU) Execute(memory *memory.Memory) {
+	if cpu.Locked {
+		cpu.Cycles += 4 // Hung by an illegal opcode
+		return
+	}
+	if cpu.switching > 0 {
+		cpu.Cycles += 4 // Waiting for the clock to settle after a speed switch
+		cpu.switching -= 4
+		return
+	}
+	if cpu.Stopped {
+		if memory.Peek(addrIF)&interruptJoypad == 0 {
+			cpu.Cycles += 4 // Asleep until a button is pressed
+			return
 		}
-
-	case 0xDA: // JP Z, a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		if cpu.F&FlagZ != 0 { // Jump if Zero flag is set
-			cpu.PC = addr
-			cpu.Cycles += 16
-		} else {
-			cpu.PC += 2
-			cpu.Cycles += 12
+		cpu.Stopped = false
+	}
+	if cpu.Halted {
+		// IF is polled at the start of each halted M-cycle. Waking takes one
+		// more M-cycle, after which a pending interrupt is dispatched if IME
+		// is set, and otherwise execution carries on after HALT.
+		if cpu.PendingInterrupts(memory) != 0 {
+			cpu.Halted = false // Any pending interrupt wakes the CPU, even with IME clear
 		}
+		cpu.Cycles += 4
+		return
+	}
 
-	// JR Instructions
-	case 0x18: // JR r8
-		offset := int8(memory.Read(cpu.PC))
-		cpu.PC += uint16(offset) + 1
-		cpu.Cycles += 12
+	// Pending interrupts are serviced between instructions
+	if cpu.HandleInterrupts(memory) {
+		return
+	}
 
-	case 0x20: // JR NZ, r8
-		offset := int8(memory.Read(cpu.PC))
-		if cpu.F&FlagZ == 0 { // Jump if Zero flag is clear
-			cpu.PC += uint16(offset)
-		}
-		cpu.PC++
-		cpu.Cycles += 12
+	if cpu.traceFunc != nil {
+		cpu.trace(memory)
+	}
 
-	case 0x28: // JR Z, r8
-		offset := int8(memory.Read(cpu.PC))
-		if cpu.F&FlagZ != 0 { // Jump if Zero flag is set
-			cpu.PC += uint16(offset)
-		}
+	pc, sp, cycles := cpu.PC, cpu.SP, cpu.Cycles // Remembered for the invariant and watchdog checks
+	opcode := memory.Fetch(cpu.PC)               // Fetch the opcode
+	if cpu.haltBug {
+		cpu.haltBug = false // The byte after HALT gets read twice
+	} else {
 		cpu.PC++
-		cpu.Cycles += 12
-
-	// CALL Instructions
-	case 0xCD: // CALL a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		cpu.Push(cpu.PC, memory) // Push current PC to stack
-		cpu.PC = addr
-		cpu.Cycles += 24
-
-	case 0xC4: // CALL NZ, a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		if cpu.F&FlagZ == 0 { // Call if Zero flag is clear
-			cpu.Push(cpu.PC, memory)
-			cpu.PC = addr
-			cpu.Cycles += 24
-		} else {
-			cpu.PC += 2
-			cpu.Cycles += 12
-		}
+	}
 
-	case 0xCC: // CALL Z, a16
-		addr := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
-		if cpu.F&FlagZ != 0 { // Call if Zero flag is set
-			cpu.Push(cpu.PC, memory)
-			cpu.PC = addr
-			cpu.Cycles += 24
+	enableIME := cpu.eiDelay // EI ran just before this instruction
+	op := &baseOpcodes[opcode]
+	if op.run == nil {
+		cpu.unknownOpcode(opcode, pc)
+	} else {
+		cpu.branched = false
+		op.run(cpu, memory)
+		if op.CyclesNotTaken != 0 && !cpu.branched {
+			cpu.Cycles += op.CyclesNotTaken
 		} else {
-			cpu.PC += 2
-			cpu.Cycles += 12
+			cpu.Cycles += op.Cycles
 		}
-	case 0x3E: // LD A, d8
-		cpu.A = memory.Read(cpu.PC) // Load immediate value into register A
-		cpu.PC++
-		cpu.Cycles += 8 // 8 cycles for LD A, d8
+	}
+	if enableIME && cpu.eiDelay {
+		cpu.IME = true // Unless DI cancelled it in the meantime
+		cpu.eiDelay = false
+	}
 
-	case 0xC6: // ADD A, d8
-		d8 := memory.Read(cpu.PC) // Get the immediate value
-		cpu.Add(d8)               // Add to A
-		cpu.PC++
-		cpu.Cycles += 8 // 8 cycles for ADD A, d8
-
-	// RET Instructions
-	case 0xC9: // RET
-		cpu.PC = cpu.Pop(memory) // Pop from stack to PC
-		cpu.Cycles += 16
-
-	case 0xD9: // RETI
-		cpu.PC = cpu.Pop(memory) // Pop from stack to PC
-		cpu.Cycles += 16
-		// Handle additional logic required for Return from Interrupt here if needed
-
-	case 0xC0: // RET NZ
-		if cpu.F&FlagZ == 0 { // Return if Zero flag is clear
-			cpu.PC = cpu.Pop(memory)
-			cpu.Cycles += 16
-		} else {
-			cpu.Cycles += 8 // If not returning, just consume cycles
-		}
+	cpu.checkInvariants(opcode, pc, cpu.Cycles-cycles)
+	if cpu.watchdog != nil {
+		cpu.watchdog.check(cpu, memory, opcode, pc, sp, op.run != nil)
+	}
+}
 
-	case 0xC8: // RET Z
-		if cpu.F&FlagZ != 0 { // Return if Zero flag is set
-			cpu.PC = cpu.Pop(memory)
-			cpu.Cycles += 16
-		} else {
-			cpu.Cycles += 8 // If not returning, just consume cycles
-		}
+// trace reports the instruction at PC to the trace callback. The bytes are
+// peeked so tracing does not show up as bus activity.
+func (cpu *CPU) trace(memory *memory.Memory) {
+	code := make([]byte, 3)
+	for i := range code {
+		code[i] = memory.Peek(cpu.PC + uint16(i))
+	}
+	text, length := Disassemble(cpu.PC, code)
+	cpu.traceFunc(TraceEvent{
+		PC:          cpu.PC,
+		Bytes:       code[:length],
+		Disassembly: text,
+		A:           cpu.A,
+		F:           cpu.F,
+		B:           cpu.B,
+		C:           cpu.C,
+		D:           cpu.D,
+		E:           cpu.E,
+		H:           cpu.H,
+		L:           cpu.L,
+		SP:          cpu.SP,
+		Cycles:      cpu.Cycles,
+	})
+}
 
-	case 0xD0: // RET NC
-		if cpu.F&FlagC == 0 { // Return if Carry flag is clear
-			cpu.PC = cpu.Pop(memory)
-			cpu.Cycles += 16
-		} else {
-			cpu.Cycles += 8 // If not returning, just consume cycles
-		}
+// Step executes one instruction like Execute, but ticks the hardware attached
+// to the bus one M-cycle at a time as the instruction runs, so the PPU and
+// timer see reads and writes on the cycle they occur. Instructions mark their
+// internal cycles with memory.Idle; cycles spent halted or stopped are ticked
+// once Execute returns. In double-speed mode the CPU-clocked hardware keeps up
+// with the CPU, while the PPU and APU get half as many cycles.
+func (cpu *CPU) Step(memory *memory.Memory) {
+	ticked := 0
+	memory.SetAccessClock(func() {
+		memory.Tick(4, cpu.doubleSpeed)
+		ticked += 4
+	})
+	defer memory.SetAccessClock(nil)
+
+	cycles := cpu.Cycles
+	cpu.Execute(memory)
+	if rest := cpu.Cycles - cycles - ticked; rest > 0 {
+		memory.Tick(rest, cpu.doubleSpeed)
+	}
+}
 
-	case 0xD8: // RET C
-		if cpu.F&FlagC != 0 { // Return if Carry flag is set
-			cpu.PC = cpu.Pop(memory)
-			cpu.Cycles += 16
-		} else {
-			cpu.Cycles += 8 // If not returning, just consume cycles
-		}
+// DoubleSpeed reports whether a CGB is running in double-speed mode, where
+// the CPU executes two cycles for each cycle of the PPU and APU
+func (cpu *CPU) DoubleSpeed() bool {
+	return cpu.doubleSpeed
+}
 
-	// Logical AND Instructions
-	case 0xA4: // AND B
-		cpu.A &= cpu.B
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xA5: // AND C
-		cpu.A &= cpu.C
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xA6: // AND (HL)
-		cpu.A &= memory.Read((uint16(cpu.H) << 8) | uint16(cpu.L))
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 8
-
-	// Logical OR Instructions
-	case 0xB0: // OR B
-		cpu.A |= cpu.B
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB1: // OR C
-		cpu.A |= cpu.C
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB2: // OR D
-		cpu.A |= cpu.D
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB3: // OR E
-		cpu.A |= cpu.E
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB4: // OR H
-		cpu.A |= cpu.H
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB5: // OR L
-		cpu.A |= cpu.L
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 4
-	case 0xB6: // OR (HL)
-		cpu.A |= memory.Read((uint16(cpu.H) << 8) | uint16(cpu.L))
-		cpu.ClearCarryFlag()
-		cpu.SetZeroFlagIfNeeded(cpu.A)
-		cpu.Cycles += 8
-
-	// BIT instructions (bit manipulation)
-	case 0xCB: // Example prefix for BIT operation
-		switch memory.Read(cpu.PC) {
-		case 0x40: // BIT 0, B
-			cpu.SetZeroFlagIfNeeded(cpu.B & 0x01)
-			cpu.Cycles += 8
-			cpu.PC++
-		case 0x41: // BIT 0, C
-			cpu.SetZeroFlagIfNeeded(cpu.C & 0x01)
-			cpu.Cycles += 8
-			cpu.PC++
-		case 0x42: // BIT 0, D
-			cpu.SetZeroFlagIfNeeded(cpu.D & 0x01)
-			cpu.Cycles += 8
-			cpu.PC++
-		// Add more BIT cases for each register...
-
-		default:
-			fmt.Printf("Unhandled BIT operation\n")
-		}
+// Register indexes as encoded in the low three bits of most opcodes
+const (
+	regB  byte = 0
+	regC  byte = 1
+	regD  byte = 2
+	regE  byte = 3
+	regH  byte = 4
+	regL  byte = 5
+	regHL byte = 6 // (HL), the byte in memory that HL points at
+	regA  byte = 7
+)
+
+// getRegister reads an 8-bit operand by its opcode encoding
+func (cpu *CPU) getRegister(reg byte, memory *memory.Memory) byte {
+	switch reg {
+	case regB:
+		return cpu.B
+	case regC:
+		return cpu.C
+	case regD:
+		return cpu.D
+	case regE:
+		return cpu.E
+	case regH:
+		return cpu.H
+	case regL:
+		return cpu.L
+	case regHL:
+		return memory.Read(cpu.HL())
+	default:
+		return cpu.A
+	}
+}
 
-	// Placeholder for timer handling (time-based operations)
-	// Timer management can be expanded later
+// setRegister writes an 8-bit operand by its opcode encoding
+func (cpu *CPU) setRegister(reg byte, value byte, memory *memory.Memory) {
+	switch reg {
+	case regB:
+		cpu.B = value
+	case regC:
+		cpu.C = value
+	case regD:
+		cpu.D = value
+	case regE:
+		cpu.E = value
+	case regH:
+		cpu.H = value
+	case regL:
+		cpu.L = value
+	case regHL:
+		memory.Write(cpu.HL(), value)
+	default:
+		cpu.A = value
+	}
+}
 
+// Register pair indexes as encoded in bits 4-5 of 16-bit opcodes
+const (
+	pairBC byte = 0
+	pairDE byte = 1
+	pairHL byte = 2
+	pairSP byte = 3
+)
+
+// getRegisterPair reads a 16-bit register by its opcode encoding
+func (cpu *CPU) getRegisterPair(pair byte) uint16 {
+	switch pair {
+	case pairBC:
+		return cpu.BC()
+	case pairDE:
+		return cpu.DE()
+	case pairHL:
+		return cpu.HL()
 	default:
-		fmt.Printf("Unknown opcode: %02X at PC: %04X\n", opcode, cpu.PC-1)
+		return cpu.SP
 	}
 }
 
+// setRegisterPair writes a 16-bit register by its opcode encoding
+func (cpu *CPU) setRegisterPair(pair byte, value uint16) {
+	switch pair {
+	case pairBC:
+		cpu.SetBC(value)
+	case pairDE:
+		cpu.SetDE(value)
+	case pairHL:
+		cpu.SetHL(value)
+	default:
+		cpu.SP = value
+	}
+}
+
+// 16-bit register pairs, high register first
+func (cpu *CPU) AF() uint16 { return uint16(cpu.A)<<8 | uint16(cpu.F) }
+func (cpu *CPU) BC() uint16 { return uint16(cpu.B)<<8 | uint16(cpu.C) }
+func (cpu *CPU) DE() uint16 { return uint16(cpu.D)<<8 | uint16(cpu.E) }
+func (cpu *CPU) HL() uint16 { return uint16(cpu.H)<<8 | uint16(cpu.L) }
+
+// SetAF writes A and F, dropping the low nibble of F that does not exist
+func (cpu *CPU) SetAF(value uint16) { cpu.A, cpu.F = byte(value>>8), byte(value)&flagMask }
+func (cpu *CPU) SetBC(value uint16) { cpu.B, cpu.C = byte(value>>8), byte(value) }
+func (cpu *CPU) SetDE(value uint16) { cpu.D, cpu.E = byte(value>>8), byte(value) }
+func (cpu *CPU) SetHL(value uint16) { cpu.H, cpu.L = byte(value>>8), byte(value) }
+
+// readD8 fetches the 8-bit immediate operand
+func (cpu *CPU) readD8(memory *memory.Memory) byte {
+	value := memory.Read(cpu.PC)
+	cpu.PC++
+	return value
+}
+
+// readD16 fetches the little-endian 16-bit immediate operand
+func (cpu *CPU) readD16(memory *memory.Memory) uint16 {
+	value := uint16(memory.Read(cpu.PC)) | (uint16(memory.Read(cpu.PC+1)) << 8)
+	cpu.PC += 2
+	return value
+}
+
 // SetZeroFlagIfNeeded sets the zero flag if the value is zero
 func (cpu *CPU) SetZeroFlagIfNeeded(value byte) {
 	if value == 0 {
@@ -272,6 +330,23 @@ func (cpu *CPU) SetZeroFlagIfNeeded(value byte) {
 	}
 }
 
+// setFlags replaces all four flags at once
+func (cpu *CPU) setFlags(z, n, h, c bool) {
+	cpu.F = 0
+	if z {
+		cpu.F |= FlagZ
+	}
+	if n {
+		cpu.F |= FlagN
+	}
+	if h {
+		cpu.F |= FlagH
+	}
+	if c {
+		cpu.F |= FlagC
+	}
+}
+
 // Helper functions to manage flags
 func (cpu *CPU) SetZeroFlag() {
 	cpu.F |= FlagZ
@@ -289,96 +364,94 @@ func (cpu *CPU) ClearCarryFlag() {
 	cpu.F &^= FlagC
 }
 
-// ADD operation
+// ADD operation: Z, N cleared, H on carry out of bit 3, C on carry out of bit 7
 func (cpu *CPU) Add(value byte) {
-	result := uint16(cpu.A) + uint16(value)
-	if result > 0xFF {
-		cpu.SetCarryFlag() // Set carry flag if there's an overflow
-	} else {
-		cpu.ClearCarryFlag()
-	}
-	cpu.A = byte(result) // Store the lower 8 bits
-	cpu.SetZeroFlagIfNeeded(cpu.A)
+	cpu.A, cpu.F = alu.Add8(cpu.A, value)
 }
 
-// SUB operation
+// SUB operation: Z, N set, H on borrow from bit 4, C on borrow
 func (cpu *CPU) Sub(value byte) {
-	result := uint16(cpu.A) - uint16(value)
-	if result == 0 {
-		cpu.SetZeroFlag()
-	} else {
-		cpu.ClearZeroFlag()
-	}
-	if result > 0xFF {
-		cpu.SetCarryFlag() // Set carry flag if there's a borrow
-	} else {
-		cpu.ClearCarryFlag()
-	}
-	cpu.A = byte(result) // Store the lower 8 bits
+	cpu.A, cpu.F = alu.Sub8(cpu.A, value)
 }
 
-// Stack operations
-func (cpu *CPU) Push(value uint16, memory memory.Memory) {
-	cpu.SP -= 2
-	memory.Write(cpu.SP, byte(value&0xFF))
-	memory.Write(cpu.SP+1, byte(value>>8))
+// ADC operation: ADD with the carry flag added in
+func (cpu *CPU) Adc(value byte) {
+	cpu.A, cpu.F = alu.Adc8(cpu.A, value, cpu.F)
 }
 
-func (cpu *CPU) Pop(memory memory.Memory) uint16 {
-	value := uint16(memory.Read(cpu.SP)) | (uint16(memory.Read(cpu.SP+1)) << 8)
-	cpu.SP += 2
-	return value
+// SBC operation: SUB with the carry flag subtracted as well
+func (cpu *CPU) Sbc(value byte) {
+	cpu.A, cpu.F = alu.Sbc8(cpu.A, value, cpu.F)
 }
 
-// SimpleMemory implementation
-type SimpleMemory struct {
-	data [65536]byte // 64 KB of memory
+// CP operation: flags as for SUB, but A is left unchanged
+func (cpu *CPU) Cp(value byte) {
+	_, cpu.F = alu.Sub8(cpu.A, value)
 }
 
-func (m *SimpleMemory) Read(addr uint16) byte {
-	return m.data[addr]
+// AND operation: Z, N cleared, H set, C cleared
+func (cpu *CPU) And(value byte) {
+	cpu.A, cpu.F = alu.And8(cpu.A, value)
 }
 
-func (m *SimpleMemory) Write(addr uint16, value byte) {
-	m.data[addr] = value
+// OR operation: Z, N, H and C cleared
+func (cpu *CPU) Or(value byte) {
+	cpu.A, cpu.F = alu.Or8(cpu.A, value)
 }
 
-// Convert boolean to int (0 or 1)
-func btoi(b bool) int {
-	if b {
-		return 1
-	}
-	return 0
+// XOR operation: Z, N, H and C cleared
+func (cpu *CPU) Xor(value byte) {
+	cpu.A, cpu.F = alu.Xor8(cpu.A, value)
 }
 
-// Main function to demonstrate CPU execution
-func main() {
-	ROMData := []byte{0x01, 0x34, 0x12, 0x02, 0x80, 0x3E, 0x0A, 0xC6, 0x02, 0xC9} // Sample ROM data
-	mem := memory.NewMemory(ROMData)                                              // Initialize memory with ROM data
-	cpu := NewCPU()
+// INC operation: Z, N cleared, H on carry out of bit 3, C untouched
+func (cpu *CPU) Inc(value byte) byte {
+	value, cpu.F = alu.Inc8(value, cpu.F)
+	return value
+}
 
-	// Initialize Accumulator A
-	cpu.A = 5 // Set Accumulator A to 5
+// DEC operation: Z, N set, H on borrow from bit 4, C untouched
+func (cpu *CPU) Dec(value byte) byte {
+	value, cpu.F = alu.Dec8(value, cpu.F)
+	return value
+}
 
-	// Execute instructions
-	for cpu.PC < uint16(len(ROMData)) {
-		cpu.Execute(mem) // Execute instructions in memory
-	}
+// ADD HL operation: Z untouched, N cleared, H on carry out of bit 11, C on
+// carry out of bit 15
+func (cpu *CPU) AddHL(value uint16) {
+	hl, f := alu.Add16(cpu.HL(), value, cpu.F)
+	cpu.SetHL(hl)
+	cpu.F = f
+}
+
+// addSPOffset computes SP plus a signed offset for ADD SP,e8 and
+// LD HL,SP+e8, setting the flags as alu.AddSP describes
+func (cpu *CPU) addSPOffset(offset int8) uint16 {
+	var result uint16
+	result, cpu.F = alu.AddSP(cpu.SP, offset)
+	return result
+}
+
+// DAA operation, adjusts A back to packed BCD after an addition or
+// subtraction using the N, H and C flags left behind by it
+func (cpu *CPU) Daa() {
+	cpu.A, cpu.F = alu.Daa(cpu.A, cpu.F)
+}
+
+// Stack operations
+
+// Push spends an internal M-cycle decrementing SP, then writes the high byte
+// followed by the low byte, as PUSH, CALL and RST do
+func (cpu *CPU) Push(value uint16, memory *memory.Memory) {
+	memory.Idle()
+	cpu.SP--
+	memory.Write(cpu.SP, byte(value>>8))
+	cpu.SP--
+	memory.Write(cpu.SP, byte(value&0xFF))
+}
 
-	// Print CPU Registers and Flags
-	fmt.Printf("A: %d (0x%02X)\n", cpu.A, cpu.A)
-	fmt.Printf("B: %d (0x%02X)\n", cpu.B, cpu.B)
-	fmt.Printf("C: %d (0x%02X)\n", cpu.C, cpu.C)
-	fmt.Printf("D: %d (0x%02X)\n", cpu.D, cpu.D)
-	fmt.Printf("E: %d (0x%02X)\n", cpu.E, cpu.E)
-	fmt.Printf("H: %d (0x%02X)\n", cpu.H, cpu.H)
-	fmt.Printf("L: %d (0x%02X)\n", cpu.L, cpu.L)
-	fmt.Printf("SP: %04X\n", cpu.SP)
-	fmt.Printf("PC: %04X\n", cpu.PC)
-	fmt.Printf("F: %02X (Flags: Z: %d, N: %d, H: %d, C: %d)\n", cpu.F,
-		btoi(cpu.F&FlagZ != 0), // Convert bool to int (0 or 1)
-		btoi(cpu.F&FlagN != 0),
-		btoi(cpu.F&FlagH != 0),
-		btoi(cpu.F&FlagC != 0),
-	)
+func (cpu *CPU) Pop(memory *memory.Memory) uint16 {
+	value := uint16(memory.Read(cpu.SP)) | (uint16(memory.Read(cpu.SP+1)) << 8)
+	cpu.SP += 2
+	return value
 }
@@ -0,0 +1,45 @@
+package cpu
+
+import (
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+// newTestMachine returns a post-boot DMG running a 32KB cartridge with
+// program at the entry point and each handler at its address
+func newTestMachine(program []byte, handlers map[uint16][]byte) (*CPU, *memory.Memory) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], program)
+	for addr, code := range handlers {
+		copy(rom[addr:], code)
+	}
+	mem := memory.NewMemory(rom)
+	return NewCPU(DMG), &mem
+}
+
+// execute runs n instructions, counting interrupt dispatches and halted
+// cycles as one each
+func execute(cpu *CPU, mem *memory.Memory, n int) {
+	for i := 0; i < n; i++ {
+		cpu.Execute(mem)
+	}
+}
+
+// stackTop returns the 16-bit value at SP
+func stackTop(cpu *CPU, mem *memory.Memory) uint16 {
+	return uint16(mem.Peek(cpu.SP)) | uint16(mem.Peek(cpu.SP+1))<<8
+}
+
+// requestInterrupt enables and raises the given interrupts
+func requestInterrupt(mem *memory.Memory, mask byte) {
+	mem.Write(memory.IEAddr, mask)
+	mem.Write(memory.IFAddr, mask)
+}
+
+func expectPC(t *testing.T, cpu *CPU, want uint16) {
+	t.Helper()
+	if cpu.PC != want {
+		t.Fatalf("PC = %04X, want %04X", cpu.PC, want)
+	}
+}
@@ -0,0 +1,37 @@
+package cpu
+
+import "fmt"
+
+// UnknownOpcodeError reports an opcode the core has no handler for. The CPU
+// skips it and carries on, so it is delivered through the error handler
+// rather than Err.
+type UnknownOpcodeError struct {
+	Opcode byte
+	PC     uint16
+}
+
+func (e *UnknownOpcodeError) Error() string {
+	return fmt.Sprintf("unknown opcode %02X at PC %04X", e.Opcode, e.PC)
+}
+
+// SetErrorHandler makes recoverable errors, currently *UnknownOpcodeError,
+// get reported to fn so library users can react without parsing the log. A
+// nil fn removes it.
+func (cpu *CPU) SetErrorHandler(fn func(error)) {
+	cpu.onError = fn
+}
+
+// unknownOpcode reports an opcode without a handler to the log and the error
+// handler
+func (cpu *CPU) unknownOpcode(opcode byte, pc uint16) {
+	if cpu.log == nil && cpu.onError == nil {
+		return
+	}
+	err := &UnknownOpcodeError{Opcode: opcode, PC: pc}
+	if cpu.log != nil {
+		fmt.Fprintln(cpu.log, err)
+	}
+	if cpu.onError != nil {
+		cpu.onError(err)
+	}
+}
@@ -0,0 +1,48 @@
+package cpu
+
+import (
+	"fmt"
+
+	"clockworkgnome/memory"
+)
+
+// illegalOpcodes are the opcodes the SM83 leaves undefined
+var illegalOpcodes = []byte{0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED, 0xF4, 0xFC, 0xFD}
+
+// IllegalOpcodeMode selects what executing an undefined opcode does
+type IllegalOpcodeMode int
+
+const (
+	IllegalOpcodeLock   IllegalOpcodeMode = iota // Hang until reset, like hardware
+	IllegalOpcodeReport                          // Hang and report an IllegalOpcodeError through Err
+)
+
+// IllegalOpcodeError reports the undefined opcode that locked up the CPU
+type IllegalOpcodeError struct {
+	Opcode byte
+	PC     uint16
+}
+
+func (e *IllegalOpcodeError) Error() string {
+	return fmt.Sprintf("illegal opcode %02X at PC %04X", e.Opcode, e.PC)
+}
+
+// SetIllegalOpcodeMode selects how undefined opcodes are handled
+func (cpu *CPU) SetIllegalOpcodeMode(mode IllegalOpcodeMode) {
+	cpu.illegalMode = mode
+}
+
+// Err returns the error that stopped the CPU, or nil while it is running
+func (cpu *CPU) Err() error {
+	return cpu.err
+}
+
+// illegal locks up the CPU with PC left on the offending opcode. Neither
+// interrupts nor the joypad wake it.
+func illegal(cpu *CPU, memory *memory.Memory) {
+	cpu.PC--
+	cpu.Locked = true
+	if cpu.illegalMode == IllegalOpcodeReport {
+		cpu.err = &IllegalOpcodeError{Opcode: memory.Peek(cpu.PC), PC: cpu.PC}
+	}
+}
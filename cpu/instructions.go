@@ -0,0 +1,406 @@
+package cpu
+
+import "clockworkgnome/memory"
+
+// handler executes one instruction after its opcode has been fetched. Cycles
+// are charged by the dispatcher from the opcode table; conditional branches
+// report a taken branch through cpu.branched.
+type handler func(cpu *CPU, memory *memory.Memory)
+
+// registerInstructions fills in the handlers of the base and CB tables
+func registerInstructions() {
+	base := &baseOpcodes
+
+	base[0x00].run = func(cpu *CPU, memory *memory.Memory) {} // NOP
+
+	// 16-bit Loads
+	for _, op := range []byte{0x01, 0x11, 0x21, 0x31} {
+		base[op].run = loadPairImmediate(op >> 4 & 0x03) // LD rr, d16
+	}
+	base[0x08].run = loadAddressSP                           // LD (a16), SP
+	base[0xF9].run = func(cpu *CPU, memory *memory.Memory) { // LD SP, HL
+		memory.Idle()
+		cpu.SP = cpu.HL()
+	}
+
+	// Indirect Loads through BC, DE and HL
+	base[0x02].run = storeA(pairBC, 0)                       // LD (BC), A
+	base[0x12].run = storeA(pairDE, 0)                       // LD (DE), A
+	base[0x22].run = storeA(pairHL, 1)                       // LD (HL+), A
+	base[0x32].run = storeA(pairHL, -1)                      // LD (HL-), A
+	base[0x0A].run = loadA(pairBC, 0)                        // LD A, (BC)
+	base[0x1A].run = loadA(pairDE, 0)                        // LD A, (DE)
+	base[0x2A].run = loadA(pairHL, 1)                        // LD A, (HL+)
+	base[0x3A].run = loadA(pairHL, -1)                       // LD A, (HL-)
+	base[0xEA].run = func(cpu *CPU, memory *memory.Memory) { // LD (a16), A
+		memory.Write(cpu.readD16(memory), cpu.A)
+	}
+	base[0xFA].run = func(cpu *CPU, memory *memory.Memory) { // LD A, (a16)
+		cpu.A = memory.Read(cpu.readD16(memory))
+	}
+
+	// High page Loads to and from 0xFF00+n, where the I/O registers live
+	base[0xE0].run = func(cpu *CPU, memory *memory.Memory) { // LDH (a8), A
+		memory.Write(0xFF00|uint16(cpu.readD8(memory)), cpu.A)
+	}
+	base[0xF0].run = func(cpu *CPU, memory *memory.Memory) { // LDH A, (a8)
+		cpu.A = memory.Read(0xFF00 | uint16(cpu.readD8(memory)))
+	}
+	base[0xE2].run = func(cpu *CPU, memory *memory.Memory) { // LD (C), A
+		memory.Write(0xFF00|uint16(cpu.C), cpu.A)
+	}
+	base[0xF2].run = func(cpu *CPU, memory *memory.Memory) { // LD A, (C)
+		cpu.A = memory.Read(0xFF00 | uint16(cpu.C))
+	}
+
+	for reg := byte(0); reg < 8; reg++ {
+		base[0x06|reg<<3].run = loadImmediate(reg) // LD r, d8
+		base[0x04|reg<<3].run = increment(reg)     // INC r
+		base[0x05|reg<<3].run = decrement(reg)     // DEC r
+
+		// 8-bit Register Loads (0x76 is HALT)
+		for src := byte(0); src < 8; src++ {
+			if op := 0x40 | reg<<3 | src; op != 0x76 {
+				base[op].run = loadRegister(reg, src) // LD r, r'
+			}
+		}
+	}
+
+	// 8-bit Arithmetic and Logic on registers, (HL) and immediates
+	aluOps := [8]func(cpu *CPU, value byte){
+		(*CPU).Add, (*CPU).Adc, (*CPU).Sub, (*CPU).Sbc,
+		(*CPU).And, (*CPU).Xor, (*CPU).Or, (*CPU).Cp,
+	}
+	for i, fn := range aluOps {
+		op := byte(i)
+		for reg := byte(0); reg < 8; reg++ {
+			base[0x80|op<<3|reg].run = aluRegister(fn, reg) // ALU A, r
+		}
+		base[0xC6|op<<3].run = aluImmediate(fn) // ALU A, d8
+	}
+
+	// Stack Instructions
+	for pair := byte(0); pair < 4; pair++ {
+		base[0xC5|pair<<4].run = pushPair(pair) // PUSH rr
+		base[0xC1|pair<<4].run = popPair(pair)  // POP rr
+	}
+
+	// 16-bit Arithmetic
+	for pair := byte(0); pair < 4; pair++ {
+		base[0x03|pair<<4].run = incrementPair(pair) // INC rr
+		base[0x0B|pair<<4].run = decrementPair(pair) // DEC rr
+		base[0x09|pair<<4].run = addHL(pair)         // ADD HL, rr
+	}
+	base[0xE8].run = func(cpu *CPU, memory *memory.Memory) { // ADD SP, e8
+		offset := int8(cpu.readD8(memory))
+		memory.Idle() // Low byte
+		memory.Idle() // High byte
+		cpu.SP = cpu.addSPOffset(offset)
+	}
+	base[0xF8].run = func(cpu *CPU, memory *memory.Memory) { // LD HL, SP+e8
+		offset := int8(cpu.readD8(memory))
+		memory.Idle()
+		cpu.SetHL(cpu.addSPOffset(offset))
+	}
+
+	// Accumulator rotates, the first four CB shifts applied to A
+	for n := byte(0); n < 4; n++ {
+		base[0x07|n<<3].run = rotateA(shiftOps[n]) // RLCA, RRCA, RLA, RRA
+	}
+
+	// Miscellaneous arithmetic
+	base[0x27].run = func(cpu *CPU, memory *memory.Memory) { cpu.Daa() } // DAA
+	base[0x2F].run = func(cpu *CPU, memory *memory.Memory) {             // CPL
+		cpu.A = ^cpu.A
+		cpu.F |= FlagN | FlagH
+	}
+	base[0x37].run = func(cpu *CPU, memory *memory.Memory) { // SCF
+		cpu.setFlags(cpu.F&FlagZ != 0, false, false, true)
+	}
+	base[0x3F].run = func(cpu *CPU, memory *memory.Memory) { // CCF
+		cpu.setFlags(cpu.F&FlagZ != 0, false, false, cpu.F&FlagC == 0)
+	}
+
+	// Jump Instructions
+	base[0xC3].run = jump(always)                            // JP a16
+	base[0xC2].run = jump(ifNZ)                              // JP NZ, a16
+	base[0xCA].run = jump(ifZ)                               // JP Z, a16
+	base[0xD2].run = jump(ifNC)                              // JP NC, a16
+	base[0xDA].run = jump(ifC)                               // JP C, a16
+	base[0xE9].run = func(cpu *CPU, memory *memory.Memory) { // JP (HL)
+		cpu.PC = cpu.HL()
+	}
+
+	// JR Instructions
+	base[0x18].run = jumpRelative(always) // JR r8
+	base[0x20].run = jumpRelative(ifNZ)   // JR NZ, r8
+	base[0x28].run = jumpRelative(ifZ)    // JR Z, r8
+	base[0x30].run = jumpRelative(ifNC)   // JR NC, r8
+	base[0x38].run = jumpRelative(ifC)    // JR C, r8
+
+	// CALL Instructions
+	base[0xCD].run = call(always) // CALL a16
+	base[0xC4].run = call(ifNZ)   // CALL NZ, a16
+	base[0xCC].run = call(ifZ)    // CALL Z, a16
+	base[0xD4].run = call(ifNC)   // CALL NC, a16
+	base[0xDC].run = call(ifC)    // CALL C, a16
+
+	// RST Instructions
+	for vector := byte(0); vector < 8; vector++ {
+		base[0xC7|vector<<3].run = restart(uint16(vector) << 3) // RST n
+	}
+
+	// RET Instructions
+	base[0xC9].run = ret         // RET
+	base[0xC0].run = retIf(ifNZ) // RET NZ
+	base[0xC8].run = retIf(ifZ)  // RET Z
+	base[0xD0].run = retIf(ifNC) // RET NC
+	base[0xD8].run = retIf(ifC)  // RET C
+	base[0xD9].run = reti        // RETI
+
+	// CPU control
+	base[0x76].run = halt              // HALT
+	base[0x10].run = stop              // STOP
+	base[0xF3].run = disableInterrupts // DI
+	base[0xFB].run = enableInterrupts  // EI
+
+	// Undefined opcodes lock up the CPU
+	for _, op := range illegalOpcodes {
+		base[op].run = illegal
+	}
+
+	// CB-prefixed rotates, shifts and bit operations
+	base[0xCB].run = prefixCB
+	registerCBInstructions()
+}
+
+// Branch conditions
+func always(cpu *CPU) bool { return true }
+func ifNZ(cpu *CPU) bool   { return cpu.F&FlagZ == 0 }
+func ifZ(cpu *CPU) bool    { return cpu.F&FlagZ != 0 }
+func ifNC(cpu *CPU) bool   { return cpu.F&FlagC == 0 }
+func ifC(cpu *CPU) bool    { return cpu.F&FlagC != 0 }
+
+func loadPairImmediate(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegisterPair(pair, cpu.readD16(memory))
+	}
+}
+
+func loadAddressSP(cpu *CPU, memory *memory.Memory) {
+	addr := cpu.readD16(memory)
+	memory.Write(addr, byte(cpu.SP&0xFF))
+	memory.Write(addr+1, byte(cpu.SP>>8))
+}
+
+// storeA writes A through a register pair, then steps the pair by delta
+func storeA(pair byte, delta int) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		addr := cpu.getRegisterPair(pair)
+		memory.Write(addr, cpu.A)
+		cpu.setRegisterPair(pair, addr+uint16(delta))
+	}
+}
+
+// loadA reads A through a register pair, then steps the pair by delta
+func loadA(pair byte, delta int) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		addr := cpu.getRegisterPair(pair)
+		cpu.A = memory.Read(addr)
+		cpu.setRegisterPair(pair, addr+uint16(delta))
+	}
+}
+
+func loadImmediate(reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(reg, cpu.readD8(memory), memory)
+	}
+}
+
+func loadRegister(dst, src byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(dst, cpu.getRegister(src, memory), memory)
+	}
+}
+
+func increment(reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(reg, cpu.Inc(cpu.getRegister(reg, memory)), memory)
+	}
+}
+
+func decrement(reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.setRegister(reg, cpu.Dec(cpu.getRegister(reg, memory)), memory)
+	}
+}
+
+func aluRegister(fn func(cpu *CPU, value byte), reg byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		fn(cpu, cpu.getRegister(reg, memory))
+	}
+}
+
+func aluImmediate(fn func(cpu *CPU, value byte)) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		fn(cpu, cpu.readD8(memory))
+	}
+}
+
+// rotateA differs from the CB rotate on A only in always clearing Z
+func rotateA(fn shiftOp) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		result, carry := fn(cpu, cpu.A)
+		cpu.A = result
+		cpu.setFlags(false, false, false, carry != 0)
+	}
+}
+
+// pushPair pushes BC, DE, HL or AF. The stack opcodes encode AF where the
+// others encode SP.
+func pushPair(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		if pair == pairSP {
+			cpu.Push(cpu.AF(), memory)
+		} else {
+			cpu.Push(cpu.getRegisterPair(pair), memory)
+		}
+	}
+}
+
+// popPair pops BC, DE, HL or AF
+func popPair(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		value := cpu.Pop(memory)
+		if pair == pairSP {
+			cpu.SetAF(value)
+		} else {
+			cpu.setRegisterPair(pair, value)
+		}
+	}
+}
+
+// incrementPair, decrementPair and addHL take an extra internal M-cycle for
+// the 16-bit result
+func incrementPair(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		memory.Idle()
+		cpu.setRegisterPair(pair, cpu.getRegisterPair(pair)+1)
+	}
+}
+
+func decrementPair(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		memory.Idle()
+		cpu.setRegisterPair(pair, cpu.getRegisterPair(pair)-1)
+	}
+}
+
+func addHL(pair byte) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		memory.Idle()
+		cpu.AddHL(cpu.getRegisterPair(pair))
+	}
+}
+
+func jump(cond func(cpu *CPU) bool) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		addr := cpu.readD16(memory)
+		if cond(cpu) {
+			memory.Idle() // Loading PC
+			cpu.PC = addr
+			cpu.branched = true
+		}
+	}
+}
+
+func jumpRelative(cond func(cpu *CPU) bool) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		offset := int8(cpu.readD8(memory))
+		if cond(cpu) {
+			memory.Idle() // Adding the offset
+			cpu.PC += uint16(offset)
+			cpu.branched = true
+		}
+	}
+}
+
+func call(cond func(cpu *CPU) bool) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		addr := cpu.readD16(memory)
+		if cond(cpu) {
+			cpu.Push(cpu.PC, memory) // Push the return address
+			cpu.PC = addr
+			cpu.branched = true
+		}
+	}
+}
+
+// restart calls one of the fixed vectors at 0x00, 0x08, ... 0x38
+func restart(vector uint16) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		cpu.Push(cpu.PC, memory)
+		cpu.PC = vector
+	}
+}
+
+// ret pops PC, which takes an internal M-cycle after the two reads
+func ret(cpu *CPU, memory *memory.Memory) {
+	cpu.PC = cpu.Pop(memory)
+	memory.Idle() // Loading PC
+}
+
+// retIf spends an internal M-cycle checking the condition before returning
+func retIf(cond func(cpu *CPU) bool) handler {
+	return func(cpu *CPU, memory *memory.Memory) {
+		memory.Idle()
+		if cond(cpu) {
+			ret(cpu, memory)
+			cpu.branched = true
+		}
+	}
+}
+
+// reti returns from an interrupt handler and sets IME straight away, without
+// the one instruction delay of EI
+func reti(cpu *CPU, memory *memory.Memory) {
+	ret(cpu, memory)
+	cpu.IME = true
+}
+
+func halt(cpu *CPU, memory *memory.Memory) {
+	if !cpu.IME && cpu.PendingInterrupts(memory) != 0 {
+		cpu.haltBug = true // Does not halt, and the next fetch repeats
+	} else {
+		cpu.Halted = true
+	}
+}
+
+func stop(cpu *CPU, memory *memory.Memory) {
+	cpu.PC++              // STOP is followed by a padding byte
+	memory.ResetDivider() // Entering STOP resets the divider
+	if cpu.Model == CGB && memory.Peek(addrKEY1)&0x01 != 0 {
+		// An armed KEY1 turns STOP into a speed switch
+		cpu.doubleSpeed = !cpu.doubleSpeed
+		key1 := byte(0x00)
+		if cpu.doubleSpeed {
+			key1 = 0x80
+		}
+		memory.PokeIO(addrKEY1, key1)
+		cpu.switching = cpu.Model.Timing().SpeedSwitchCycles
+		return
+	}
+	cpu.Stopped = true
+}
+
+// disableInterrupts clears IME at once, cancelling an EI still in its delay
+func disableInterrupts(cpu *CPU, memory *memory.Memory) {
+	cpu.IME = false
+	cpu.eiDelay = false
+}
+
+// enableInterrupts sets IME only after the next instruction, so EI followed
+// by RET returns before any interrupt is serviced
+func enableInterrupts(cpu *CPU, memory *memory.Memory) {
+	cpu.eiDelay = true
+}
@@ -0,0 +1,92 @@
+package cpu
+
+import "testing"
+
+func TestAccumulatorRotatesClearZ(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode byte // Accumulator form, the CB form has the same number
+		a      byte
+		want   byte
+		carry  bool // Carry out of the rotate
+	}{
+		{"RLCA", 0x07, 0x00, 0x00, false},
+		{"RRCA", 0x0F, 0x00, 0x00, false},
+		{"RLA", 0x17, 0x00, 0x00, false},
+		{"RLA", 0x17, 0x80, 0x00, true},
+		{"RRA", 0x1F, 0x00, 0x00, false},
+		{"RRA", 0x1F, 0x01, 0x00, true},
+		{"RLCA", 0x07, 0x85, 0x0B, true},
+		{"RRA", 0x1F, 0x02, 0x01, false},
+	}
+	for _, tt := range tests {
+		var wantC byte
+		if tt.carry {
+			wantC = FlagC
+		}
+		var wantZ byte
+		if tt.want == 0 {
+			wantZ = FlagZ
+		}
+
+		cpu, mem := newTestMachine([]byte{tt.opcode}, nil)
+		cpu.A, cpu.F = tt.a, FlagZ|FlagN|FlagH
+		execute(cpu, mem, 1)
+		if cpu.A != tt.want || cpu.F != wantC {
+			t.Errorf("%s A=%02X: A=%02X F=%02X, want A=%02X F=%02X", tt.name, tt.a, cpu.A, cpu.F, tt.want, wantC)
+		}
+
+		cpu, mem = newTestMachine([]byte{0xCB, tt.opcode}, nil)
+		cpu.A, cpu.F = tt.a, FlagN|FlagH
+		execute(cpu, mem, 1)
+		if cpu.A != tt.want || cpu.F != wantZ|wantC {
+			t.Errorf("CB %s A=%02X: A=%02X F=%02X, want A=%02X F=%02X", tt.name, tt.a, cpu.A, cpu.F, tt.want, wantZ|wantC)
+		}
+	}
+}
+
+func TestPushPopRoundTrip(t *testing.T) {
+	// PUSH BC, DE, HL, AF then POP them back in reverse order into
+	// different pairs: POP HL, POP DE, POP BC, POP AF
+	program := []byte{0xC5, 0xD5, 0xE5, 0xF5, 0xE1, 0xD1, 0xC1, 0xF1}
+	cpu, mem := newTestMachine(program, nil)
+	cpu.SetBC(0x1234)
+	cpu.SetDE(0x5678)
+	cpu.SetHL(0x9ABC)
+	cpu.A, cpu.F = 0xDE, FlagZ|FlagC
+	cpu.SP = 0xDFF0
+
+	execute(cpu, mem, 4)
+	if cpu.SP != 0xDFE8 {
+		t.Fatalf("SP = %04X after four pushes, want DFE8", cpu.SP)
+	}
+	// Pushed high byte at the higher address
+	if mem.Peek(0xDFEF) != 0x12 || mem.Peek(0xDFEE) != 0x34 {
+		t.Errorf("BC pushed as %02X %02X", mem.Peek(0xDFEF), mem.Peek(0xDFEE))
+	}
+
+	// Put junk in the low nibble of the stacked F before it is popped
+	mem.Write(0xDFEE, 0x3F)
+	execute(cpu, mem, 4)
+	if cpu.SP != 0xDFF0 {
+		t.Errorf("SP = %04X after four pops, want DFF0", cpu.SP)
+	}
+	if cpu.HL() != 0xDE90 || cpu.DE() != 0x9ABC || cpu.BC() != 0x5678 {
+		t.Errorf("HL=%04X DE=%04X BC=%04X, want DE90 9ABC 5678", cpu.HL(), cpu.DE(), cpu.BC())
+	}
+	// POP AF drops the low nibble of F, which does not exist
+	if cpu.A != 0x12 || cpu.F != 0x30 {
+		t.Errorf("AF = %02X%02X, want 1230", cpu.A, cpu.F)
+	}
+}
+
+func TestPopTableFromROM(t *testing.T) {
+	// LD SP,$0200 then POP BC, POP DE reads a table out of ROM, which checked
+	// builds must allow
+	program := []byte{0x31, 0x00, 0x02, 0xC1, 0xD1}
+	cpu, mem := newTestMachine(program, map[uint16][]byte{0x0200: {0x34, 0x12, 0x78, 0x56}})
+	execute(cpu, mem, 3)
+	if cpu.BC() != 0x1234 || cpu.DE() != 0x5678 || cpu.SP != 0x0204 {
+		t.Errorf("BC=%04X DE=%04X SP=%04X, want 1234 5678 0204", cpu.BC(), cpu.DE(), cpu.SP)
+	}
+}
@@ -0,0 +1,64 @@
+package cpu
+
+import "clockworkgnome/memory"
+
+// Interrupt vectors, indexed by IE/IF bit
+var interruptVectors = [5]uint16{
+	0x0040, // VBlank
+	0x0048, // LCD STAT
+	0x0050, // Timer
+	0x0058, // Serial
+	0x0060, // Joypad
+}
+
+// HandleInterrupts services the highest priority interrupt that is both
+// enabled and requested, if IME is set. Dispatch takes 5 M-cycles: two idle
+// cycles, the two bytes of PC pushed high byte first, then the jump. It
+// clears IME and the IF bit of the interrupt taken and reports whether
+// dispatch started.
+//
+// The interrupt is only chosen once the high byte of PC is on the stack. If
+// that push lands on IE (SP was 0x0000) it can disable the interrupt being
+// dispatched, and with nothing left pending PC ends up at 0x0000 instead of
+// a vector, as mooneye's ie_push test checks.
+func (cpu *CPU) HandleInterrupts(memory *memory.Memory) bool {
+	if !cpu.IME || cpu.PendingInterrupts(memory) == 0 {
+		return false
+	}
+
+	cpu.IME = false
+	if cpu.haltBug {
+		// EI; HALT with an interrupt pending. The halt bug kept PC on the
+		// prefetched byte, so winding back returns to the HALT, which
+		// runs again.
+		cpu.PC--
+		cpu.haltBug = false
+	}
+	memory.Idle() // PC is wound back to the instruction that was prefetched
+	memory.Idle() // SP is decremented
+	cpu.SP--
+	memory.Write(cpu.SP, byte(cpu.PC>>8))
+	pending := cpu.PendingInterrupts(memory) // Re-read in case the push hit IE
+
+	pc := cpu.PC
+	cpu.PC = 0x0000 // Where a cancelled dispatch ends up
+	for bit, vector := range interruptVectors {
+		mask := byte(1) << bit
+		if pending&mask != 0 {
+			memory.AcknowledgeInterrupt(mask)
+			cpu.PC = vector
+			break
+		}
+	}
+	cpu.SP--
+	memory.Write(cpu.SP, byte(pc))
+	memory.Idle() // PC is loaded with the vector
+	cpu.Cycles += 20
+	return true
+}
+
+// PendingInterrupts returns the interrupts that are both enabled and
+// requested, regardless of IME
+func (cpu *CPU) PendingInterrupts(memory *memory.Memory) byte {
+	return memory.Peek(addrIE) & memory.Peek(addrIF) & 0x1F
+}
@@ -0,0 +1,218 @@
+package cpu
+
+import (
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+const (
+	opNOP  = 0x00
+	opHALT = 0x76
+	opRETI = 0xD9
+	opDI   = 0xF3
+	opEI   = 0xFB
+)
+
+func TestEIDelay(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opEI, opNOP, opNOP}, nil)
+	requestInterrupt(mem, memory.InterruptVBlank)
+
+	execute(cpu, mem, 1)
+	if cpu.IME {
+		t.Fatal("IME set straight after EI")
+	}
+	execute(cpu, mem, 1) // The NOP after EI still runs
+	expectPC(t, cpu, 0x0102)
+	if !cpu.IME {
+		t.Fatal("IME clear after the instruction following EI")
+	}
+	execute(cpu, mem, 1)
+	expectPC(t, cpu, 0x0040)
+	if ret := stackTop(cpu, mem); ret != 0x0102 {
+		t.Errorf("returns to %04X, want 0102", ret)
+	}
+}
+
+func TestEIThenDICancels(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opEI, opDI, opNOP, opNOP}, nil)
+	requestInterrupt(mem, memory.InterruptVBlank)
+
+	execute(cpu, mem, 4)
+	expectPC(t, cpu, 0x0104)
+	if cpu.IME {
+		t.Error("IME set although DI followed EI")
+	}
+	if mem.Peek(memory.IFAddr)&memory.InterruptVBlank == 0 {
+		t.Error("interrupt acknowledged without being serviced")
+	}
+}
+
+func TestEIBackToBack(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opEI, opEI, opNOP}, nil)
+	requestInterrupt(mem, memory.InterruptVBlank)
+
+	// The second EI is the instruction after the first, so IME is set once
+	// it completes and the interrupt is taken before the NOP
+	execute(cpu, mem, 3)
+	expectPC(t, cpu, 0x0040)
+	if ret := stackTop(cpu, mem); ret != 0x0102 {
+		t.Errorf("returns to %04X, want 0102", ret)
+	}
+}
+
+func TestEIHalt(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opEI, opHALT, opNOP}, map[uint16][]byte{0x0040: {opRETI}})
+	requestInterrupt(mem, memory.InterruptVBlank)
+
+	// The interrupt is pending as HALT runs with IME still clear, so the
+	// halt bug kicks in. The handler then returns to the HALT itself.
+	execute(cpu, mem, 3)
+	expectPC(t, cpu, 0x0040)
+	if ret := stackTop(cpu, mem); ret != 0x0101 {
+		t.Errorf("returns to %04X, want 0101 (the HALT)", ret)
+	}
+	execute(cpu, mem, 1)
+	expectPC(t, cpu, 0x0101)
+	execute(cpu, mem, 1)
+	if !cpu.Halted {
+		t.Error("HALT did not halt once nothing was pending")
+	}
+	expectPC(t, cpu, 0x0102)
+}
+
+func TestRETIEnablesAtOnce(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opNOP, opNOP}, map[uint16][]byte{
+		0x0040: {opRETI},
+		0x0050: {opRETI},
+	})
+	cpu.IME = true
+	requestInterrupt(mem, memory.InterruptVBlank)
+	mem.Write(memory.IEAddr, memory.InterruptVBlank|memory.InterruptTimer)
+
+	execute(cpu, mem, 1)
+	expectPC(t, cpu, 0x0040)
+
+	// The timer fires while the VBlank handler runs, with IME clear
+	mem.RequestInterrupt(memory.InterruptTimer)
+	execute(cpu, mem, 1)
+	expectPC(t, cpu, 0x0100)
+	if !cpu.IME {
+		t.Fatal("IME clear after RETI")
+	}
+
+	// Unlike EI, RETI has no delay: the timer is taken before the NOP
+	execute(cpu, mem, 1)
+	expectPC(t, cpu, 0x0050)
+	if ret := stackTop(cpu, mem); ret != 0x0100 {
+		t.Errorf("returns to %04X, want 0100", ret)
+	}
+}
+
+func TestRETDoesNotEnable(t *testing.T) {
+	cpu, mem := newTestMachine([]byte{opNOP, opNOP}, map[uint16][]byte{0x0040: {0xC9}}) // RET
+	cpu.IME = true
+	requestInterrupt(mem, memory.InterruptVBlank)
+	mem.Write(memory.IEAddr, memory.InterruptVBlank|memory.InterruptTimer)
+
+	execute(cpu, mem, 1)
+	mem.RequestInterrupt(memory.InterruptTimer)
+	execute(cpu, mem, 2) // RET, then the NOP runs
+	expectPC(t, cpu, 0x0101)
+	if cpu.IME {
+		t.Error("IME set after RET")
+	}
+}
+
+// irqAt is hardware that raises an interrupt once it has been clocked for
+// a given number of cycles
+type irqAt struct {
+	mem    *memory.Memory
+	mask   byte
+	at     int
+	cycles int
+}
+
+func (i *irqAt) Tick(cycles int) {
+	if i.cycles < i.at && i.cycles+cycles >= i.at {
+		i.mem.RequestInterrupt(i.mask)
+	}
+	i.cycles += cycles
+}
+
+// stepUntil steps the CPU until done holds and returns the cycle count then
+func stepUntil(t *testing.T, cpu *CPU, mem *memory.Memory, done func() bool) int {
+	t.Helper()
+	for cpu.Cycles < 1000 {
+		cpu.Step(mem)
+		if done() {
+			return cpu.Cycles
+		}
+	}
+	t.Fatal("condition never held")
+	return 0
+}
+
+func TestHaltWakeTiming(t *testing.T) {
+	// A timer interrupt at cycle 200 wakes a HALT loop. The CPU notices it
+	// on the next halted M-cycle, spends one M-cycle waking, then either
+	// dispatches (5 M-cycles) or runs the LD A,d8 after HALT (2 M-cycles).
+	program := []byte{
+		opHALT,
+		0x3E, 0x42, // LD A,$42
+		0x18, 0xFB, // JR to the HALT
+	}
+	for _, tt := range []struct {
+		ime  bool
+		done func(cpu *CPU) bool
+		want int
+	}{
+		{true, func(cpu *CPU) bool { return cpu.PC == 0x0050 }, 24},
+		{false, func(cpu *CPU) bool { return cpu.A == 0x42 }, 12},
+	} {
+		cpu, mem := newTestMachine(program, nil)
+		cpu.IME, cpu.A = tt.ime, 0x00
+		mem.Write(memory.IEAddr, memory.InterruptTimer)
+		mem.AddTicker(&irqAt{mem: mem, mask: memory.InterruptTimer, at: 200})
+
+		got := stepUntil(t, cpu, mem, func() bool { return tt.done(cpu) }) - 200
+		if got != tt.want {
+			t.Errorf("IME=%v: %d cycles from the interrupt, want %d", tt.ime, got, tt.want)
+		}
+		if !tt.ime && mem.Peek(memory.IFAddr)&memory.InterruptTimer == 0 {
+			t.Error("IME=false: the interrupt was acknowledged")
+		}
+	}
+}
+
+func TestHaltWithInterruptPending(t *testing.T) {
+	// As in mooneye's halt_ime1_timing: with IME set and an interrupt
+	// raised while HALT is fetched, so already pending as it executes, HALT
+	// still halts and takes the wake M-cycle before the dispatch, 4 + 4 +
+	// 20 cycles in all
+	cpu, mem := newTestMachine([]byte{opHALT, opNOP}, nil)
+	cpu.IME = true
+	mem.Write(memory.IEAddr, memory.InterruptTimer)
+	mem.AddTicker(&irqAt{mem: mem, mask: memory.InterruptTimer, at: 4})
+
+	got := stepUntil(t, cpu, mem, func() bool { return cpu.PC == 0x0050 })
+	if got != 28 {
+		t.Errorf("handler entered after %d cycles, want 28", got)
+	}
+	if ret := stackTop(cpu, mem); ret != 0x0101 {
+		t.Errorf("returns to %04X, want 0101", ret)
+	}
+
+	// With IME clear the halt bug applies instead and HALT does not halt
+	cpu, mem = newTestMachine([]byte{opHALT, 0x3C}, nil) // INC A
+	cpu.A = 0
+	requestInterrupt(mem, memory.InterruptTimer)
+	execute(cpu, mem, 1)
+	if cpu.Halted {
+		t.Fatal("halted with IME clear and an interrupt pending")
+	}
+	execute(cpu, mem, 2)
+	if cpu.A != 2 || cpu.PC != 0x0102 {
+		t.Errorf("A=%d PC=%04X after the halt bug, want INC A twice and PC 0102", cpu.A, cpu.PC)
+	}
+}
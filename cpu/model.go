@@ -0,0 +1,85 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+
+	"clockworkgnome/memory"
+)
+
+// Model is a Game Boy hardware revision
+type Model int
+
+const (
+	DMG Model = iota // Original Game Boy
+	MGB              // Game Boy Pocket
+	SGB              // Super Game Boy
+	CGB              // Game Boy Color, running in color mode
+)
+
+var modelNames = [...]string{"DMG", "MGB", "SGB", "CGB"}
+
+func (m Model) String() string {
+	if int(m) < len(modelNames) {
+		return modelNames[m]
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// ParseModel looks up a model by name, ignoring case
+func ParseModel(name string) (Model, error) {
+	for i, n := range modelNames {
+		if strings.EqualFold(name, n) {
+			return Model(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hardware model %q", name)
+}
+
+// postBootRegisters are A, F, B, C, D, E, H and L as each model's boot ROM
+// leaves them. Games that skip the boot ROM check A to detect the hardware.
+var postBootRegisters = [...][8]byte{
+	DMG: {0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	MGB: {0xFF, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	SGB: {0x01, 0x00, 0x00, 0x14, 0x00, 0x00, 0xC0, 0x60},
+	CGB: {0x11, 0x80, 0x00, 0x00, 0xFF, 0x56, 0x00, 0x0D},
+}
+
+// postBootIO are I/O registers the boot ROM leaves with a non-zero value
+var postBootIO = map[uint16]byte{
+	0xFF07: 0xF8, // TAC
+	0xFF0F: 0xE1, // IF, VBlank requested
+	0xFF10: 0x80, // NR10
+	0xFF11: 0xBF, // NR11
+	0xFF12: 0xF3, // NR12
+	0xFF14: 0xBF, // NR14
+	0xFF16: 0x3F, // NR21
+	0xFF19: 0xBF, // NR24
+	0xFF1A: 0x7F, // NR30
+	0xFF1B: 0xFF, // NR31
+	0xFF1C: 0x9F, // NR32
+	0xFF1E: 0xBF, // NR34
+	0xFF20: 0xFF, // NR41
+	0xFF23: 0xBF, // NR44
+	0xFF24: 0x77, // NR50
+	0xFF25: 0xF3, // NR51
+	0xFF26: 0xF1, // NR52
+	0xFF40: 0x91, // LCDC, LCD and background on
+	0xFF41: 0x85, // STAT
+	0xFF46: 0xFF, // DMA
+	0xFF47: 0xFC, // BGP
+}
+
+// InitIO sets the I/O registers to the values the model's boot ROM leaves
+// behind, for running a cartridge without one
+func (m Model) InitIO(mem *memory.Memory) {
+	for addr, value := range postBootIO {
+		mem.PokeIO(addr, value)
+	}
+	switch m {
+	case DMG, MGB:
+		mem.PokeIO(memory.DIVAddr, 0xAB) // Depends on how long the boot ROM ran
+	case SGB:
+		mem.PokeIO(0xFF26, 0xF0) // NR52, the SGB routes sound through the SNES
+	}
+}
@@ -0,0 +1,393 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpcodeInfo describes one instruction for disassemblers, assemblers and
+// documentation tools
+type OpcodeInfo struct {
+	Mnemonic       string `json:"mnemonic"`
+	Length         int    `json:"length"`                     // Bytes including the opcode (and CB prefix)
+	Cycles         int    `json:"cycles"`                     // T-cycles, or when a branch is taken
+	CyclesNotTaken int    `json:"cycles_not_taken,omitempty"` // T-cycles when a branch is not taken
+	Flags          string `json:"flags"`                      // Effect on Z, N, H and C: flag name, 0, 1 or - (unchanged)
+	Implemented    bool   `json:"implemented"`                // Whether Execute handles the opcode yet
+
+	run handler // Executes the instruction, nil while it is unimplemented
+}
+
+// OpcodeEntry pairs an opcode with its metadata, as exported by Opcodes
+type OpcodeEntry struct {
+	Opcode string `json:"opcode"`
+	OpcodeInfo
+}
+
+// Opcodes returns the base instruction table
+func Opcodes() []OpcodeEntry {
+	return entries(&baseOpcodes, "%02X")
+}
+
+// CBOpcodes returns the 0xCB-prefixed instruction table
+func CBOpcodes() []OpcodeEntry {
+	return entries(&cbOpcodes, "CB%02X")
+}
+
+// LookupOpcode returns the metadata of a base opcode
+func LookupOpcode(opcode byte) OpcodeInfo {
+	return baseOpcodes[opcode]
+}
+
+// LookupCBOpcode returns the metadata of a 0xCB-prefixed opcode
+func LookupCBOpcode(opcode byte) OpcodeInfo {
+	return cbOpcodes[opcode]
+}
+
+// Disassemble formats the instruction at the start of code, which was read
+// from address pc, with its operands filled into the mnemonic. It returns
+// the text and the instruction length. Missing operand bytes read as zero.
+func Disassemble(pc uint16, code []byte) (string, int) {
+	at := func(i int) byte {
+		if i < len(code) {
+			return code[i]
+		}
+		return 0
+	}
+	info := baseOpcodes[at(0)]
+	if at(0) == 0xCB {
+		info = cbOpcodes[at(1)]
+	}
+
+	text := info.Mnemonic
+	word := uint16(at(1)) | uint16(at(2))<<8
+	offset := int8(at(1))
+	switch {
+	case strings.Contains(text, "n16"):
+		text = strings.Replace(text, "n16", fmt.Sprintf("$%04X", word), 1)
+	case strings.Contains(text, "a16"):
+		text = strings.Replace(text, "a16", fmt.Sprintf("$%04X", word), 1)
+	case strings.Contains(text, "a8"):
+		text = strings.Replace(text, "a8", fmt.Sprintf("$FF%02X", at(1)), 1)
+	case strings.Contains(text, "n8"):
+		text = strings.Replace(text, "n8", fmt.Sprintf("$%02X", at(1)), 1)
+	case strings.HasPrefix(text, "JR"):
+		target := pc + uint16(info.Length) + uint16(offset) // Relative to the next instruction
+		text = strings.Replace(text, "e8", fmt.Sprintf("$%04X", target), 1)
+	case strings.Contains(text, "SP+e8"):
+		text = strings.Replace(text, "SP+e8", fmt.Sprintf("SP%+d", offset), 1)
+	case strings.Contains(text, "e8"):
+		text = strings.Replace(text, "e8", fmt.Sprintf("%d", offset), 1)
+	}
+	return text, info.Length
+}
+
+func entries(table *[256]OpcodeInfo, format string) []OpcodeEntry {
+	list := make([]OpcodeEntry, len(table))
+	for i, info := range table {
+		list[i] = OpcodeEntry{Opcode: fmt.Sprintf(format, i), OpcodeInfo: info}
+	}
+	return list
+}
+
+func op(mnemonic string, length, cycles, cyclesNotTaken int, flags string) OpcodeInfo {
+	return OpcodeInfo{Mnemonic: mnemonic, Length: length, Cycles: cycles, CyclesNotTaken: cyclesNotTaken, Flags: flags}
+}
+
+// baseOpcodes is the unprefixed instruction table that Execute dispatches
+// through
+var baseOpcodes = [256]OpcodeInfo{
+	0x00: op("NOP", 1, 4, 0, "----"),
+	0x01: op("LD BC, n16", 3, 12, 0, "----"),
+	0x02: op("LD (BC), A", 1, 8, 0, "----"),
+	0x03: op("INC BC", 1, 8, 0, "----"),
+	0x04: op("INC B", 1, 4, 0, "Z0H-"),
+	0x05: op("DEC B", 1, 4, 0, "Z1H-"),
+	0x06: op("LD B, n8", 2, 8, 0, "----"),
+	0x07: op("RLCA", 1, 4, 0, "000C"),
+	0x08: op("LD (a16), SP", 3, 20, 0, "----"),
+	0x09: op("ADD HL, BC", 1, 8, 0, "-0HC"),
+	0x0A: op("LD A, (BC)", 1, 8, 0, "----"),
+	0x0B: op("DEC BC", 1, 8, 0, "----"),
+	0x0C: op("INC C", 1, 4, 0, "Z0H-"),
+	0x0D: op("DEC C", 1, 4, 0, "Z1H-"),
+	0x0E: op("LD C, n8", 2, 8, 0, "----"),
+	0x0F: op("RRCA", 1, 4, 0, "000C"),
+	0x10: op("STOP n8", 2, 4, 0, "----"),
+	0x11: op("LD DE, n16", 3, 12, 0, "----"),
+	0x12: op("LD (DE), A", 1, 8, 0, "----"),
+	0x13: op("INC DE", 1, 8, 0, "----"),
+	0x14: op("INC D", 1, 4, 0, "Z0H-"),
+	0x15: op("DEC D", 1, 4, 0, "Z1H-"),
+	0x16: op("LD D, n8", 2, 8, 0, "----"),
+	0x17: op("RLA", 1, 4, 0, "000C"),
+	0x18: op("JR e8", 2, 12, 0, "----"),
+	0x19: op("ADD HL, DE", 1, 8, 0, "-0HC"),
+	0x1A: op("LD A, (DE)", 1, 8, 0, "----"),
+	0x1B: op("DEC DE", 1, 8, 0, "----"),
+	0x1C: op("INC E", 1, 4, 0, "Z0H-"),
+	0x1D: op("DEC E", 1, 4, 0, "Z1H-"),
+	0x1E: op("LD E, n8", 2, 8, 0, "----"),
+	0x1F: op("RRA", 1, 4, 0, "000C"),
+	0x20: op("JR NZ, e8", 2, 12, 8, "----"),
+	0x21: op("LD HL, n16", 3, 12, 0, "----"),
+	0x22: op("LD (HL+), A", 1, 8, 0, "----"),
+	0x23: op("INC HL", 1, 8, 0, "----"),
+	0x24: op("INC H", 1, 4, 0, "Z0H-"),
+	0x25: op("DEC H", 1, 4, 0, "Z1H-"),
+	0x26: op("LD H, n8", 2, 8, 0, "----"),
+	0x27: op("DAA", 1, 4, 0, "Z-0C"),
+	0x28: op("JR Z, e8", 2, 12, 8, "----"),
+	0x29: op("ADD HL, HL", 1, 8, 0, "-0HC"),
+	0x2A: op("LD A, (HL+)", 1, 8, 0, "----"),
+	0x2B: op("DEC HL", 1, 8, 0, "----"),
+	0x2C: op("INC L", 1, 4, 0, "Z0H-"),
+	0x2D: op("DEC L", 1, 4, 0, "Z1H-"),
+	0x2E: op("LD L, n8", 2, 8, 0, "----"),
+	0x2F: op("CPL", 1, 4, 0, "-11-"),
+	0x30: op("JR NC, e8", 2, 12, 8, "----"),
+	0x31: op("LD SP, n16", 3, 12, 0, "----"),
+	0x32: op("LD (HL-), A", 1, 8, 0, "----"),
+	0x33: op("INC SP", 1, 8, 0, "----"),
+	0x34: op("INC (HL)", 1, 12, 0, "Z0H-"),
+	0x35: op("DEC (HL)", 1, 12, 0, "Z1H-"),
+	0x36: op("LD (HL), n8", 2, 12, 0, "----"),
+	0x37: op("SCF", 1, 4, 0, "-001"),
+	0x38: op("JR C, e8", 2, 12, 8, "----"),
+	0x39: op("ADD HL, SP", 1, 8, 0, "-0HC"),
+	0x3A: op("LD A, (HL-)", 1, 8, 0, "----"),
+	0x3B: op("DEC SP", 1, 8, 0, "----"),
+	0x3C: op("INC A", 1, 4, 0, "Z0H-"),
+	0x3D: op("DEC A", 1, 4, 0, "Z1H-"),
+	0x3E: op("LD A, n8", 2, 8, 0, "----"),
+	0x3F: op("CCF", 1, 4, 0, "-00C"),
+	0x40: op("LD B, B", 1, 4, 0, "----"),
+	0x41: op("LD B, C", 1, 4, 0, "----"),
+	0x42: op("LD B, D", 1, 4, 0, "----"),
+	0x43: op("LD B, E", 1, 4, 0, "----"),
+	0x44: op("LD B, H", 1, 4, 0, "----"),
+	0x45: op("LD B, L", 1, 4, 0, "----"),
+	0x46: op("LD B, (HL)", 1, 8, 0, "----"),
+	0x47: op("LD B, A", 1, 4, 0, "----"),
+	0x48: op("LD C, B", 1, 4, 0, "----"),
+	0x49: op("LD C, C", 1, 4, 0, "----"),
+	0x4A: op("LD C, D", 1, 4, 0, "----"),
+	0x4B: op("LD C, E", 1, 4, 0, "----"),
+	0x4C: op("LD C, H", 1, 4, 0, "----"),
+	0x4D: op("LD C, L", 1, 4, 0, "----"),
+	0x4E: op("LD C, (HL)", 1, 8, 0, "----"),
+	0x4F: op("LD C, A", 1, 4, 0, "----"),
+	0x50: op("LD D, B", 1, 4, 0, "----"),
+	0x51: op("LD D, C", 1, 4, 0, "----"),
+	0x52: op("LD D, D", 1, 4, 0, "----"),
+	0x53: op("LD D, E", 1, 4, 0, "----"),
+	0x54: op("LD D, H", 1, 4, 0, "----"),
+	0x55: op("LD D, L", 1, 4, 0, "----"),
+	0x56: op("LD D, (HL)", 1, 8, 0, "----"),
+	0x57: op("LD D, A", 1, 4, 0, "----"),
+	0x58: op("LD E, B", 1, 4, 0, "----"),
+	0x59: op("LD E, C", 1, 4, 0, "----"),
+	0x5A: op("LD E, D", 1, 4, 0, "----"),
+	0x5B: op("LD E, E", 1, 4, 0, "----"),
+	0x5C: op("LD E, H", 1, 4, 0, "----"),
+	0x5D: op("LD E, L", 1, 4, 0, "----"),
+	0x5E: op("LD E, (HL)", 1, 8, 0, "----"),
+	0x5F: op("LD E, A", 1, 4, 0, "----"),
+	0x60: op("LD H, B", 1, 4, 0, "----"),
+	0x61: op("LD H, C", 1, 4, 0, "----"),
+	0x62: op("LD H, D", 1, 4, 0, "----"),
+	0x63: op("LD H, E", 1, 4, 0, "----"),
+	0x64: op("LD H, H", 1, 4, 0, "----"),
+	0x65: op("LD H, L", 1, 4, 0, "----"),
+	0x66: op("LD H, (HL)", 1, 8, 0, "----"),
+	0x67: op("LD H, A", 1, 4, 0, "----"),
+	0x68: op("LD L, B", 1, 4, 0, "----"),
+	0x69: op("LD L, C", 1, 4, 0, "----"),
+	0x6A: op("LD L, D", 1, 4, 0, "----"),
+	0x6B: op("LD L, E", 1, 4, 0, "----"),
+	0x6C: op("LD L, H", 1, 4, 0, "----"),
+	0x6D: op("LD L, L", 1, 4, 0, "----"),
+	0x6E: op("LD L, (HL)", 1, 8, 0, "----"),
+	0x6F: op("LD L, A", 1, 4, 0, "----"),
+	0x70: op("LD (HL), B", 1, 8, 0, "----"),
+	0x71: op("LD (HL), C", 1, 8, 0, "----"),
+	0x72: op("LD (HL), D", 1, 8, 0, "----"),
+	0x73: op("LD (HL), E", 1, 8, 0, "----"),
+	0x74: op("LD (HL), H", 1, 8, 0, "----"),
+	0x75: op("LD (HL), L", 1, 8, 0, "----"),
+	0x76: op("HALT", 1, 4, 0, "----"),
+	0x77: op("LD (HL), A", 1, 8, 0, "----"),
+	0x78: op("LD A, B", 1, 4, 0, "----"),
+	0x79: op("LD A, C", 1, 4, 0, "----"),
+	0x7A: op("LD A, D", 1, 4, 0, "----"),
+	0x7B: op("LD A, E", 1, 4, 0, "----"),
+	0x7C: op("LD A, H", 1, 4, 0, "----"),
+	0x7D: op("LD A, L", 1, 4, 0, "----"),
+	0x7E: op("LD A, (HL)", 1, 8, 0, "----"),
+	0x7F: op("LD A, A", 1, 4, 0, "----"),
+	0x80: op("ADD A, B", 1, 4, 0, "Z0HC"),
+	0x81: op("ADD A, C", 1, 4, 0, "Z0HC"),
+	0x82: op("ADD A, D", 1, 4, 0, "Z0HC"),
+	0x83: op("ADD A, E", 1, 4, 0, "Z0HC"),
+	0x84: op("ADD A, H", 1, 4, 0, "Z0HC"),
+	0x85: op("ADD A, L", 1, 4, 0, "Z0HC"),
+	0x86: op("ADD A, (HL)", 1, 8, 0, "Z0HC"),
+	0x87: op("ADD A, A", 1, 4, 0, "Z0HC"),
+	0x88: op("ADC A, B", 1, 4, 0, "Z0HC"),
+	0x89: op("ADC A, C", 1, 4, 0, "Z0HC"),
+	0x8A: op("ADC A, D", 1, 4, 0, "Z0HC"),
+	0x8B: op("ADC A, E", 1, 4, 0, "Z0HC"),
+	0x8C: op("ADC A, H", 1, 4, 0, "Z0HC"),
+	0x8D: op("ADC A, L", 1, 4, 0, "Z0HC"),
+	0x8E: op("ADC A, (HL)", 1, 8, 0, "Z0HC"),
+	0x8F: op("ADC A, A", 1, 4, 0, "Z0HC"),
+	0x90: op("SUB B", 1, 4, 0, "Z1HC"),
+	0x91: op("SUB C", 1, 4, 0, "Z1HC"),
+	0x92: op("SUB D", 1, 4, 0, "Z1HC"),
+	0x93: op("SUB E", 1, 4, 0, "Z1HC"),
+	0x94: op("SUB H", 1, 4, 0, "Z1HC"),
+	0x95: op("SUB L", 1, 4, 0, "Z1HC"),
+	0x96: op("SUB (HL)", 1, 8, 0, "Z1HC"),
+	0x97: op("SUB A", 1, 4, 0, "Z1HC"),
+	0x98: op("SBC A, B", 1, 4, 0, "Z1HC"),
+	0x99: op("SBC A, C", 1, 4, 0, "Z1HC"),
+	0x9A: op("SBC A, D", 1, 4, 0, "Z1HC"),
+	0x9B: op("SBC A, E", 1, 4, 0, "Z1HC"),
+	0x9C: op("SBC A, H", 1, 4, 0, "Z1HC"),
+	0x9D: op("SBC A, L", 1, 4, 0, "Z1HC"),
+	0x9E: op("SBC A, (HL)", 1, 8, 0, "Z1HC"),
+	0x9F: op("SBC A, A", 1, 4, 0, "Z1HC"),
+	0xA0: op("AND B", 1, 4, 0, "Z010"),
+	0xA1: op("AND C", 1, 4, 0, "Z010"),
+	0xA2: op("AND D", 1, 4, 0, "Z010"),
+	0xA3: op("AND E", 1, 4, 0, "Z010"),
+	0xA4: op("AND H", 1, 4, 0, "Z010"),
+	0xA5: op("AND L", 1, 4, 0, "Z010"),
+	0xA6: op("AND (HL)", 1, 8, 0, "Z010"),
+	0xA7: op("AND A", 1, 4, 0, "Z010"),
+	0xA8: op("XOR B", 1, 4, 0, "Z000"),
+	0xA9: op("XOR C", 1, 4, 0, "Z000"),
+	0xAA: op("XOR D", 1, 4, 0, "Z000"),
+	0xAB: op("XOR E", 1, 4, 0, "Z000"),
+	0xAC: op("XOR H", 1, 4, 0, "Z000"),
+	0xAD: op("XOR L", 1, 4, 0, "Z000"),
+	0xAE: op("XOR (HL)", 1, 8, 0, "Z000"),
+	0xAF: op("XOR A", 1, 4, 0, "Z000"),
+	0xB0: op("OR B", 1, 4, 0, "Z000"),
+	0xB1: op("OR C", 1, 4, 0, "Z000"),
+	0xB2: op("OR D", 1, 4, 0, "Z000"),
+	0xB3: op("OR E", 1, 4, 0, "Z000"),
+	0xB4: op("OR H", 1, 4, 0, "Z000"),
+	0xB5: op("OR L", 1, 4, 0, "Z000"),
+	0xB6: op("OR (HL)", 1, 8, 0, "Z000"),
+	0xB7: op("OR A", 1, 4, 0, "Z000"),
+	0xB8: op("CP B", 1, 4, 0, "Z1HC"),
+	0xB9: op("CP C", 1, 4, 0, "Z1HC"),
+	0xBA: op("CP D", 1, 4, 0, "Z1HC"),
+	0xBB: op("CP E", 1, 4, 0, "Z1HC"),
+	0xBC: op("CP H", 1, 4, 0, "Z1HC"),
+	0xBD: op("CP L", 1, 4, 0, "Z1HC"),
+	0xBE: op("CP (HL)", 1, 8, 0, "Z1HC"),
+	0xBF: op("CP A", 1, 4, 0, "Z1HC"),
+	0xC0: op("RET NZ", 1, 20, 8, "----"),
+	0xC1: op("POP BC", 1, 12, 0, "----"),
+	0xC2: op("JP NZ, a16", 3, 16, 12, "----"),
+	0xC3: op("JP a16", 3, 16, 0, "----"),
+	0xC4: op("CALL NZ, a16", 3, 24, 12, "----"),
+	0xC5: op("PUSH BC", 1, 16, 0, "----"),
+	0xC6: op("ADD A, n8", 2, 8, 0, "Z0HC"),
+	0xC7: op("RST $00", 1, 16, 0, "----"),
+	0xC8: op("RET Z", 1, 20, 8, "----"),
+	0xC9: op("RET", 1, 16, 0, "----"),
+	0xCA: op("JP Z, a16", 3, 16, 12, "----"),
+	0xCB: op("PREFIX CB", 1, 4, 0, "----"),
+	0xCC: op("CALL Z, a16", 3, 24, 12, "----"),
+	0xCD: op("CALL a16", 3, 24, 0, "----"),
+	0xCE: op("ADC A, n8", 2, 8, 0, "Z0HC"),
+	0xCF: op("RST $08", 1, 16, 0, "----"),
+	0xD0: op("RET NC", 1, 20, 8, "----"),
+	0xD1: op("POP DE", 1, 12, 0, "----"),
+	0xD2: op("JP NC, a16", 3, 16, 12, "----"),
+	0xD3: op("ILLEGAL_D3", 1, 4, 0, "----"),
+	0xD4: op("CALL NC, a16", 3, 24, 12, "----"),
+	0xD5: op("PUSH DE", 1, 16, 0, "----"),
+	0xD6: op("SUB n8", 2, 8, 0, "Z1HC"),
+	0xD7: op("RST $10", 1, 16, 0, "----"),
+	0xD8: op("RET C", 1, 20, 8, "----"),
+	0xD9: op("RETI", 1, 16, 0, "----"),
+	0xDA: op("JP C, a16", 3, 16, 12, "----"),
+	0xDB: op("ILLEGAL_DB", 1, 4, 0, "----"),
+	0xDC: op("CALL C, a16", 3, 24, 12, "----"),
+	0xDD: op("ILLEGAL_DD", 1, 4, 0, "----"),
+	0xDE: op("SBC A, n8", 2, 8, 0, "Z1HC"),
+	0xDF: op("RST $18", 1, 16, 0, "----"),
+	0xE0: op("LDH (a8), A", 2, 12, 0, "----"),
+	0xE1: op("POP HL", 1, 12, 0, "----"),
+	0xE2: op("LD (C), A", 1, 8, 0, "----"),
+	0xE3: op("ILLEGAL_E3", 1, 4, 0, "----"),
+	0xE4: op("ILLEGAL_E4", 1, 4, 0, "----"),
+	0xE5: op("PUSH HL", 1, 16, 0, "----"),
+	0xE6: op("AND n8", 2, 8, 0, "Z010"),
+	0xE7: op("RST $20", 1, 16, 0, "----"),
+	0xE8: op("ADD SP, e8", 2, 16, 0, "00HC"),
+	0xE9: op("JP HL", 1, 4, 0, "----"),
+	0xEA: op("LD (a16), A", 3, 16, 0, "----"),
+	0xEB: op("ILLEGAL_EB", 1, 4, 0, "----"),
+	0xEC: op("ILLEGAL_EC", 1, 4, 0, "----"),
+	0xED: op("ILLEGAL_ED", 1, 4, 0, "----"),
+	0xEE: op("XOR n8", 2, 8, 0, "Z000"),
+	0xEF: op("RST $28", 1, 16, 0, "----"),
+	0xF0: op("LDH A, (a8)", 2, 12, 0, "----"),
+	0xF1: op("POP AF", 1, 12, 0, "ZNHC"),
+	0xF2: op("LD A, (C)", 1, 8, 0, "----"),
+	0xF3: op("DI", 1, 4, 0, "----"),
+	0xF4: op("ILLEGAL_F4", 1, 4, 0, "----"),
+	0xF5: op("PUSH AF", 1, 16, 0, "----"),
+	0xF6: op("OR n8", 2, 8, 0, "Z000"),
+	0xF7: op("RST $30", 1, 16, 0, "----"),
+	0xF8: op("LD HL, SP+e8", 2, 12, 0, "00HC"),
+	0xF9: op("LD SP, HL", 1, 8, 0, "----"),
+	0xFA: op("LD A, (a16)", 3, 16, 0, "----"),
+	0xFB: op("EI", 1, 4, 0, "----"),
+	0xFC: op("ILLEGAL_FC", 1, 4, 0, "----"),
+	0xFD: op("ILLEGAL_FD", 1, 4, 0, "----"),
+	0xFE: op("CP n8", 2, 8, 0, "Z1HC"),
+	0xFF: op("RST $38", 1, 16, 0, "----"),
+}
+
+// cbOpcodes is the 0xCB-prefixed instruction table, built from its regular
+// encoding
+var cbOpcodes [256]OpcodeInfo
+
+func init() {
+	registers := []string{"B", "C", "D", "E", "H", "L", "(HL)", "A"}
+	shifts := []string{"RLC", "RRC", "RL", "RR", "SLA", "SRA", "SWAP", "SRL"}
+
+	for op := 0; op < 256; op++ {
+		reg, n := op&0x07, op>>3&0x07
+		info := OpcodeInfo{Length: 2, Cycles: cbCycles(byte(reg), 16), Flags: "----"}
+		switch op >> 6 {
+		case 0:
+			info.Mnemonic = fmt.Sprintf("%s %s", shifts[n], registers[reg])
+			info.Flags = "Z00C"
+			if n == 6 {
+				info.Flags = "Z000" // SWAP always clears carry
+			}
+		case 1:
+			info.Mnemonic = fmt.Sprintf("BIT %d, %s", n, registers[reg])
+			info.Cycles = cbCycles(byte(reg), 12)
+			info.Flags = "Z01-"
+		case 2:
+			info.Mnemonic = fmt.Sprintf("RES %d, %s", n, registers[reg])
+		case 3:
+			info.Mnemonic = fmt.Sprintf("SET %d, %s", n, registers[reg])
+		}
+		cbOpcodes[op] = info
+	}
+
+	registerInstructions()
+	for i := range baseOpcodes {
+		baseOpcodes[i].Implemented = baseOpcodes[i].run != nil
+		cbOpcodes[i].Implemented = cbOpcodes[i].run != nil
+	}
+}
@@ -0,0 +1,63 @@
+package cpu
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// cpuState is the fixed-size on-disk layout of the CPU
+type cpuState struct {
+	A, F, B, C, D, E, H, L byte
+	SP, PC                 uint16
+	Cycles                 int64
+	IME                    bool
+	Timer                  int64
+
+	// Version 2: execution state between instructions
+	Halted, Stopped, Locked bool
+	HaltBug, EIDelay        bool
+	DoubleSpeed             bool
+	Switching               int32
+}
+
+// Version of the CPU save state layout
+func (cpu *CPU) Version() uint16 {
+	return 2
+}
+
+// Save writes every register and counter to w, along with the halt, stop and
+// pending EI state an instruction boundary can be caught in
+func (cpu *CPU) Save(w io.Writer) error {
+	s := cpuState{
+		A: cpu.A, F: cpu.F, B: cpu.B, C: cpu.C,
+		D: cpu.D, E: cpu.E, H: cpu.H, L: cpu.L,
+		SP: cpu.SP, PC: cpu.PC,
+		Cycles: int64(cpu.Cycles),
+		IME:    cpu.IME,
+		Timer:  int64(cpu.Timer),
+
+		Halted: cpu.Halted, Stopped: cpu.Stopped, Locked: cpu.Locked,
+		HaltBug: cpu.haltBug, EIDelay: cpu.eiDelay,
+		DoubleSpeed: cpu.doubleSpeed,
+		Switching:   int32(cpu.switching),
+	}
+	return binary.Write(w, binary.LittleEndian, &s)
+}
+
+// Load restores the state written by Save
+func (cpu *CPU) Load(r io.Reader) error {
+	var s cpuState
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	cpu.A, cpu.F, cpu.B, cpu.C = s.A, s.F&flagMask, s.B, s.C
+	cpu.D, cpu.E, cpu.H, cpu.L = s.D, s.E, s.H, s.L
+	cpu.SP, cpu.PC = s.SP, s.PC
+	cpu.Cycles = int(s.Cycles)
+	cpu.IME = s.IME
+	cpu.Timer = int(s.Timer)
+	cpu.Halted, cpu.Stopped, cpu.Locked = s.Halted, s.Stopped, s.Locked
+	cpu.haltBug, cpu.eiDelay = s.HaltBug, s.EIDelay
+	cpu.doubleSpeed, cpu.switching = s.DoubleSpeed, int(s.Switching)
+	return nil
+}
@@ -0,0 +1,66 @@
+package cpu
+
+// Timing holds the clock rate and hardware durations of a model, in T-cycles
+// of its CPU clock at normal speed. Subsystems take their periods from here
+// rather than hard-coding DMG values.
+type Timing struct {
+	ClockHz           int    // CPU clock
+	FrameCycles       int    // One video frame, 154 scanlines
+	LineCycles        int    // One scanline
+	OAMScanCycles     int    // PPU mode 2
+	DrawCycles        int    // PPU mode 3 at its shortest, HBlank takes the rest of the line
+	DMACycles         int    // OAM DMA of 160 bytes
+	SerialBitCycles   int    // One bit of an internally clocked serial transfer
+	DividerCycles     int    // Between DIV increments
+	TimerCycles       [4]int // Between TIMA increments, indexed by the TAC clock select
+	SpeedSwitchCycles int    // CPU pause after a speed switch, 0 for models without one
+}
+
+// dmgTiming is the baseline every model shares unless it says otherwise
+var dmgTiming = Timing{
+	ClockHz:         4194304,
+	FrameCycles:     70224,
+	LineCycles:      456,
+	OAMScanCycles:   80,
+	DrawCycles:      172,
+	DMACycles:       640,
+	SerialBitCycles: 512, // 8192 Hz
+	DividerCycles:   256, // 16384 Hz
+	TimerCycles:     [4]int{1024, 16, 64, 256},
+}
+
+var modelTimings = [...]Timing{
+	DMG: dmgTiming,
+	MGB: dmgTiming,
+	SGB: func() Timing {
+		t := dmgTiming
+		t.ClockHz = 4295454 // Derived from the SNES master clock, about 2.4% fast
+		return t
+	}(),
+	CGB: func() Timing {
+		t := dmgTiming
+		t.SpeedSwitchCycles = 2050 * 4
+		return t
+	}(),
+}
+
+// Timing returns the model's hardware timing at normal speed
+func (m Model) Timing() Timing {
+	return modelTimings[m]
+}
+
+// Timing returns the model's hardware timing relative to the CPU at its
+// current speed. In double-speed mode the CPU runs twice as many cycles per
+// frame and scanline, while DIV, TIMA, serial and DMA stay tied to the CPU
+// clock.
+func (cpu *CPU) Timing() Timing {
+	t := cpu.Model.Timing()
+	if cpu.doubleSpeed {
+		t.ClockHz *= 2
+		t.FrameCycles *= 2
+		t.LineCycles *= 2
+		t.OAMScanCycles *= 2
+		t.DrawCycles *= 2
+	}
+	return t
+}
@@ -0,0 +1,52 @@
+package cpu
+
+import (
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+// divider stands in for the timer, incrementing DIV every period CPU cycles
+type divider struct {
+	mem    *memory.Memory
+	period int
+	cycles int
+}
+
+func (d *divider) Tick(cycles int) {
+	for d.cycles += cycles; d.cycles >= d.period; d.cycles -= d.period {
+		d.mem.PokeIO(memory.DIVAddr, d.mem.PeekIO(memory.DIVAddr)+1)
+	}
+}
+
+// dots counts the cycles the PPU sees
+type dots int
+
+func (d *dots) Tick(cycles int) { *d += dots(cycles) }
+
+func TestStepDoubleSpeedDivider(t *testing.T) {
+	// Over the same 4096 PPU dots, DIV counts 16 times at normal speed and
+	// 32 times in double speed
+	for _, tt := range []struct {
+		doubleSpeed bool
+		want        byte
+	}{
+		{false, 16},
+		{true, 32},
+	} {
+		cpu, mem := newTestMachine([]byte{0x18, 0xFE}, nil) // JR to itself
+		cpu.Model = CGB
+		cpu.doubleSpeed = tt.doubleSpeed
+		mem.AddTicker(&divider{mem: mem, period: cpu.Timing().DividerCycles})
+		var ppu dots
+		mem.AddNormalSpeedTicker(&ppu)
+
+		start := mem.PeekIO(memory.DIVAddr)
+		for ppu < 4096 {
+			cpu.Step(mem)
+		}
+		if got := mem.PeekIO(memory.DIVAddr) - start; got != tt.want {
+			t.Errorf("double speed %v: DIV advanced %d times, want %d", tt.doubleSpeed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package cpu
+
+// TraceEvent describes the instruction the CPU is about to execute
+type TraceEvent struct {
+	PC          uint16
+	Bytes       []byte // Opcode and operand bytes
+	Disassembly string
+	A, F        byte
+	B, C        byte
+	D, E        byte
+	H, L        byte
+	SP          uint16
+	Cycles      int // Cycle count before the instruction
+}
+
+// SetTraceFunc installs a callback fired before every instruction, or
+// removes it when fn is nil
+func (cpu *CPU) SetTraceFunc(fn func(TraceEvent)) {
+	cpu.traceFunc = fn
+}
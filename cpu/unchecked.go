@@ -0,0 +1,9 @@
+//go:build !checked
+
+package cpu
+
+// Checked reports whether emulation invariants are asserted (-tags checked)
+const Checked = false
+
+// checkInvariants compiles away in release builds
+func (cpu *CPU) checkInvariants(opcode byte, pc uint16, cycles int) {}
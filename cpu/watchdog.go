@@ -0,0 +1,174 @@
+package cpu
+
+import (
+	"fmt"
+
+	"clockworkgnome/memory"
+)
+
+// FaultKind identifies the pathological state a Watchdog detected
+type FaultKind int
+
+const (
+	FaultUnknownOpcodes  FaultKind = iota + 1 // PC keeps landing on unimplemented opcodes
+	FaultStackWrap                            // SP wrapped around the address space
+	FaultInvalidAccesses                      // Too many invalid bus accesses in one frame
+	FaultStackOverflow                        // Pushes ran below the stack's memory region
+	FaultStackUnderflow                       // Pops ran above the stack's memory region
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultUnknownOpcodes:
+		return "unknown opcode loop"
+	case FaultStackWrap:
+		return "stack pointer wrapped"
+	case FaultInvalidAccesses:
+		return "invalid memory access storm"
+	case FaultStackOverflow:
+		return "stack overflow"
+	case FaultStackUnderflow:
+		return "stack underflow"
+	default:
+		return "unknown fault"
+	}
+}
+
+// Fault is an emulation fault with the diagnostics needed to investigate it
+type Fault struct {
+	Kind   FaultKind
+	PC     uint16 // Address of the instruction that tripped the watchdog
+	Opcode byte   // Opcode at PC
+	SP     uint16 // Stack pointer after the instruction
+	Cycles int    // CPU cycle count when the fault was raised
+	Count  int    // Unknown opcodes in a row, or invalid accesses this frame
+}
+
+func (f Fault) Error() string {
+	msg := fmt.Sprintf("%s at PC %04X (opcode %02X, SP %04X, cycle %d)", f.Kind, f.PC, f.Opcode, f.SP, f.Cycles)
+	switch f.Kind {
+	case FaultUnknownOpcodes:
+		msg += fmt.Sprintf(": %d unknown opcodes in a row", f.Count)
+	case FaultInvalidAccesses:
+		msg += fmt.Sprintf(": %d invalid accesses this frame", f.Count)
+	}
+	return msg
+}
+
+// Watchdog watches the CPU for runaway emulation and reports each fault once
+// through OnFault
+type Watchdog struct {
+	MaxUnknownOpcodes  int  // Unknown opcodes in a row before a fault
+	MaxInvalidAccesses int  // Invalid bus accesses within a frame before a fault
+	GuardStack         bool // Fault when SP leaves the memory region the game put the stack in
+	OnFault            func(Fault)
+
+	unknown    int // Unknown opcodes executed in a row
+	frameStart int // Cycle count at the start of the current frame
+	frameBase  int // Invalid access count at the start of the current frame
+	stormed    bool
+
+	stack        stackRegion // Region the stack was set up in
+	stackSet     bool        // Whether stack holds a region yet
+	stackStrayed bool        // SP is outside the region and was reported
+}
+
+// stackRegion is a memory area a stack can live in. SP may sit one past end,
+// where an empty stack starts.
+type stackRegion struct {
+	start, end uint16
+}
+
+var stackRegions = [...]stackRegion{
+	{memory.ExternalRAMStart, memory.ExternalRAMEnd},
+	{memory.InternalRAM0Start, memory.InternalRAM1End},
+	{memory.HRAMStart, memory.HRAMEnd - 1}, // IE sits at the top of HRAM
+}
+
+// stackRegionOf finds the region the first push from sp lands in
+func stackRegionOf(sp uint16) (stackRegion, bool) {
+	for _, r := range stackRegions {
+		if sp-1 >= r.start && sp-1 <= r.end {
+			return r, true
+		}
+	}
+	return stackRegion{}, false
+}
+
+// NewWatchdog returns a watchdog with default thresholds
+func NewWatchdog(onFault func(Fault)) *Watchdog {
+	return &Watchdog{
+		MaxUnknownOpcodes:  16,
+		MaxInvalidAccesses: 1000,
+		OnFault:            onFault,
+	}
+}
+
+// SetWatchdog attaches a watchdog, or detaches it when w is nil
+func (cpu *CPU) SetWatchdog(w *Watchdog) {
+	cpu.watchdog = w
+}
+
+// check runs after every executed instruction
+func (w *Watchdog) check(cpu *CPU, memory *memory.Memory, opcode byte, pc, sp uint16, known bool) {
+	fault := Fault{PC: pc, Opcode: opcode, SP: cpu.SP, Cycles: cpu.Cycles}
+
+	if known {
+		w.unknown = 0
+	} else if w.unknown++; w.unknown == w.MaxUnknownOpcodes {
+		fault.Kind, fault.Count = FaultUnknownOpcodes, w.unknown
+		w.OnFault(fault)
+	}
+
+	// Pushes and pops move SP by two, so a jump of more than half the
+	// address space means it wrapped. LD SP sets it outright.
+	if opcode != 0x31 && opcode != 0xF9 {
+		if delta := int(cpu.SP) - int(sp); delta > 0x8000 || delta < -0x8000 {
+			fault.Kind, fault.Count = FaultStackWrap, 0
+			w.OnFault(fault)
+		}
+	}
+
+	if w.GuardStack {
+		w.checkStack(cpu, opcode, sp, fault)
+	}
+
+	if cpu.Cycles-w.frameStart >= cpu.Timing().FrameCycles {
+		w.frameStart = cpu.Cycles
+		w.frameBase = memory.InvalidAccesses()
+		w.stormed = false
+	}
+	if count := memory.InvalidAccesses() - w.frameBase; count >= w.MaxInvalidAccesses && !w.stormed {
+		w.stormed = true
+		fault.Kind, fault.Count = FaultInvalidAccesses, count
+		w.OnFault(fault)
+	}
+}
+
+// checkStack follows the region established by LD SP, or wherever SP pointed
+// when the guard first ran, and reports SP leaving it. Homebrew that lets the
+// stack grow into OAM or I/O otherwise silently corrupts hardware registers.
+func (w *Watchdog) checkStack(cpu *CPU, opcode byte, sp uint16, fault Fault) {
+	if opcode == 0x31 || opcode == 0xF9 || !w.stackSet {
+		w.stack, w.stackSet = stackRegionOf(cpu.SP)
+		w.stackStrayed = false
+		return
+	}
+	if cpu.SP >= w.stack.start && cpu.SP <= w.stack.end+1 {
+		w.stackStrayed = false
+		return
+	}
+	if w.stackStrayed {
+		return // Report once until SP comes back
+	}
+	w.stackStrayed = true
+
+	// Go by the direction SP moved, so popping past 0xFFFF is still an
+	// underflow. SP can also have left during interrupt dispatch, between
+	// checks, in which case only its position is known.
+	fault.Kind = FaultStackOverflow
+	if delta := int16(cpu.SP - sp); delta > 0 || delta == 0 && cpu.SP > w.stack.end {
+		fault.Kind = FaultStackUnderflow
+	}
+	w.OnFault(fault)
+}
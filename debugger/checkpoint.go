@@ -0,0 +1,121 @@
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+	"clockworkgnome/state"
+)
+
+// Checkpoints captures a save state whenever one of its trigger expressions
+// changes value, such as a map ID in RAM, so a practice run can jump back to
+// the start of the current room
+type Checkpoints struct {
+	triggers []trigger
+	saved    []byte // State captured at the last checkpoint, nil before the first
+	name     string // Trigger that captured it
+}
+
+type trigger struct {
+	name   string
+	expr   Expr
+	value  int
+	primed bool // Whether value holds a previous result
+}
+
+// NewCheckpoints returns an empty set of triggers
+func NewCheckpoints() *Checkpoints {
+	return &Checkpoints{}
+}
+
+// ParseCheckpoints reads per-game trigger definitions, one "name = expression"
+// per line. Blank lines and lines starting with # are ignored.
+//
+//	# Pokemon Red
+//	map = [$D35E]
+func ParseCheckpoints(r io.Reader) (*Checkpoints, error) {
+	c := NewCheckpoints()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, src, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected name = expression", line)
+		}
+		if err := c.Add(strings.TrimSpace(name), src); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return c, scanner.Err()
+}
+
+// Add registers a trigger that captures a checkpoint each time the value of
+// the expression changes
+func (c *Checkpoints) Add(name, src string) error {
+	expr, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	c.triggers = append(c.triggers, trigger{name: name, expr: expr})
+	return nil
+}
+
+// Update evaluates every trigger, typically once per instruction or frame,
+// and captures the machine state if any of them changed. It returns the name
+// of the trigger that fired, or "" when none did. The first evaluation of a
+// trigger only records its value.
+func (c *Checkpoints) Update(cpu *cpu.CPU, mem *memory.Memory) (string, error) {
+	fired := ""
+	for i := range c.triggers {
+		t := &c.triggers[i]
+		value, err := t.expr.Eval(cpu, mem)
+		if err != nil {
+			return "", fmt.Errorf("checkpoint %s: %w", t.name, err)
+		}
+		if t.primed && value != t.value && fired == "" {
+			fired = t.name
+		}
+		t.value, t.primed = value, true
+	}
+	if fired == "" {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := state.Save(&buf, machineState(cpu, mem)...); err != nil {
+		return "", err
+	}
+	c.saved, c.name = buf.Bytes(), fired
+	return fired, nil
+}
+
+// Last returns the trigger that captured the most recent checkpoint, or ""
+// if none has been captured yet
+func (c *Checkpoints) Last() string {
+	return c.name
+}
+
+// Rewind restores the machine to the most recent checkpoint. Triggers keep
+// their current values, so returning to the checkpoint does not fire one.
+func (c *Checkpoints) Rewind(cpu *cpu.CPU, mem *memory.Memory) error {
+	if c.saved == nil {
+		return fmt.Errorf("no checkpoint captured yet")
+	}
+	return state.Load(bytes.NewReader(c.saved), machineState(cpu, mem)...)
+}
+
+// machineState lists the subsystems a checkpoint covers
+func machineState(cpu *cpu.CPU, mem *memory.Memory) []state.Section {
+	return []state.Section{
+		{Name: "cpu", S: cpu},
+		{Name: "memory", S: mem},
+	}
+}
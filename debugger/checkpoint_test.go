@@ -0,0 +1,65 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+)
+
+func TestCheckpointsCaptureAndRewind(t *testing.T) {
+	checkpoints, err := ParseCheckpoints(strings.NewReader("# Test game\nroom = [$C100]\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	c := cpu.NewCPU(cpu.DMG)
+	var accesses countingTracer
+	mem.SetTracer(&accesses)
+
+	if name, err := checkpoints.Update(c, &mem); name != "" || err != nil {
+		t.Fatalf("first update fired %q, %v", name, err)
+	}
+	mem.Write(0xC100, 1) // Enter room 1
+	c.PC, c.A = 0x1234, 0x56
+	accesses = 0
+	if name, err := checkpoints.Update(c, &mem); name != "room" || err != nil {
+		t.Fatalf("room change fired %q, %v", name, err)
+	}
+	if accesses != 0 {
+		t.Errorf("update made %d bus accesses, want 0", accesses)
+	}
+
+	// Progress through the room, then go back
+	c.PC, c.A = 0x2000, 0x00
+	mem.Write(0xC200, 0xAA)
+	if name, _ := checkpoints.Update(c, &mem); name != "" {
+		t.Fatalf("unchanged trigger fired %q", name)
+	}
+	if err := checkpoints.Rewind(c, &mem); err != nil {
+		t.Fatal(err)
+	}
+	if c.PC != 0x1234 || c.A != 0x56 || mem.Peek(0xC200) != 0x00 {
+		t.Errorf("rewind restored PC=%04X A=%02X [C200]=%02X", c.PC, c.A, mem.Peek(0xC200))
+	}
+	if checkpoints.Last() != "room" {
+		t.Errorf("Last() = %q, want room", checkpoints.Last())
+	}
+	if name, _ := checkpoints.Update(c, &mem); name != "" {
+		t.Errorf("returning to the checkpoint fired %q", name)
+	}
+}
+
+func TestCheckpointsErrors(t *testing.T) {
+	if _, err := ParseCheckpoints(strings.NewReader("room [$C100]")); err == nil {
+		t.Error("missing = accepted")
+	}
+	if _, err := ParseCheckpoints(strings.NewReader("room = [$C100")); err == nil {
+		t.Error("bad expression accepted")
+	}
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	if err := NewCheckpoints().Rewind(cpu.NewCPU(cpu.DMG), &mem); err == nil {
+		t.Error("rewind without a checkpoint succeeded")
+	}
+}
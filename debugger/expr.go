@@ -0,0 +1,420 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+)
+
+// Expr is a parsed debugger expression that can be evaluated repeatedly
+// against the machine state (conditional breakpoints, watches, logpoints)
+type Expr interface {
+	Eval(cpu *cpu.CPU, mem *memory.Memory) (int, error)
+}
+
+// Eval parses and evaluates an expression in one go
+func Eval(src string, cpu *cpu.CPU, mem *memory.Memory) (int, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return 0, err
+	}
+	return e.Eval(cpu, mem)
+}
+
+// Parse compiles an expression such as "a == 0x10 && [hl] != 0" or
+// "bank() == 1 && pc >= $4000". Supported operands are registers
+// (a f b c d e h l af bc de hl sp pc), flags (zf nf hf cf), numbers
+// (decimal, 0x / $ hex), [addr] byte dereference and bank().
+func Parse(src string) (Expr, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.tok.text, p.tok.pos)
+	}
+	return e, nil
+}
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokNum
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string
+	num  int
+	pos  int
+}
+
+// lexer splits the source into tokens
+type lexer struct {
+	src string
+	pos int
+}
+
+// Operators, longest first so "<=" wins over "<"
+var operators = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"<", ">", "+", "-", "*", "/", "%", "&", "|", "^", "!", "~",
+	"(", ")", "[", "]",
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+	start := l.pos
+	ch := l.src[l.pos]
+
+	switch {
+	case ch == '$' || isDigit(ch):
+		// Number literal: $FF, 0xFF or 255
+		l.pos++
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		n, err := parseNumber(text)
+		if err != nil {
+			return token{}, fmt.Errorf("bad number %q at offset %d", text, start)
+		}
+		return token{kind: tokNum, text: text, num: n, pos: start}, nil
+	case isIdentChar(ch):
+		// Register, flag or function name
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: strings.ToLower(l.src[start:l.pos]), pos: start}, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character %q at offset %d", ch, start)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentChar(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+}
+
+func parseNumber(text string) (int, error) {
+	var (
+		n   int64
+		err error
+	)
+	switch {
+	case strings.HasPrefix(text, "$"):
+		n, err = strconv.ParseInt(text[1:], 16, 32)
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		n, err = strconv.ParseInt(text[2:], 16, 32)
+	default:
+		n, err = strconv.ParseInt(text, 10, 32)
+	}
+	return int(n), err
+}
+
+// parser is a precedence-climbing parser over the lexer's tokens
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+// Binary operator precedence, higher binds tighter. The levels are Go's, so
+// the bitwise operators bind tighter than comparisons and "f & $80 == $80"
+// tests bit 7.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4, "|": 4, "^": 4,
+	"*": 5, "/": 5, "%": 5, "<<": 5, ">>": 5, "&": 5,
+}
+
+func (p *parser) parseBinary(minPrec int) (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.err != nil {
+			return nil, p.err
+		}
+		prec, ok := precedence[p.tok.text]
+		if p.tok.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		op := p.tok.text
+		p.next()
+		right, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == tokOp && (p.tok.text == "-" || p.tok.text == "!" || p.tok.text == "~") {
+		op := p.tok.text
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNum:
+		p.next()
+		return numberExpr(tok.num), nil
+
+	case tok.kind == tokIdent:
+		p.next()
+		if p.tok.kind == tokOp && p.tok.text == "(" {
+			return p.parseCall(tok)
+		}
+		if _, ok := registers[tok.text]; !ok {
+			return nil, fmt.Errorf("unknown register or flag %q at offset %d", tok.text, tok.pos)
+		}
+		return registerExpr(tok.text), nil
+
+	case tok.kind == tokOp && tok.text == "(":
+		p.next()
+		inner, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case tok.kind == tokOp && tok.text == "[":
+		p.next()
+		addr, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return derefExpr{addr: addr}, nil
+
+	case tok.kind == tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+// parseCall handles the built-in functions, currently only bank()
+func (p *parser) parseCall(name token) (Expr, error) {
+	p.next() // consume "("
+	if name.text != "bank" {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return bankExpr{}, nil
+}
+
+func (p *parser) expect(op string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok.kind != tokOp || p.tok.text != op {
+		if p.tok.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q, got %q at offset %d", op, p.tok.text, p.tok.pos)
+	}
+	p.next()
+	return nil
+}
+
+// Expression nodes
+
+type numberExpr int
+
+func (n numberExpr) Eval(*cpu.CPU, *memory.Memory) (int, error) {
+	return int(n), nil
+}
+
+type registerExpr string
+
+// registers maps register and flag names to their current value
+var registers = map[string]func(c *cpu.CPU) int{
+	"a":  func(c *cpu.CPU) int { return int(c.A) },
+	"f":  func(c *cpu.CPU) int { return int(c.F) },
+	"b":  func(c *cpu.CPU) int { return int(c.B) },
+	"c":  func(c *cpu.CPU) int { return int(c.C) },
+	"d":  func(c *cpu.CPU) int { return int(c.D) },
+	"e":  func(c *cpu.CPU) int { return int(c.E) },
+	"h":  func(c *cpu.CPU) int { return int(c.H) },
+	"l":  func(c *cpu.CPU) int { return int(c.L) },
+	"af": func(c *cpu.CPU) int { return int(c.AF()) },
+	"bc": func(c *cpu.CPU) int { return int(c.BC()) },
+	"de": func(c *cpu.CPU) int { return int(c.DE()) },
+	"hl": func(c *cpu.CPU) int { return int(c.HL()) },
+	"sp": func(c *cpu.CPU) int { return int(c.SP) },
+	"pc": func(c *cpu.CPU) int { return int(c.PC) },
+	"zf": func(c *cpu.CPU) int { return btoi(c.F&cpu.FlagZ != 0) },
+	"nf": func(c *cpu.CPU) int { return btoi(c.F&cpu.FlagN != 0) },
+	"hf": func(c *cpu.CPU) int { return btoi(c.F&cpu.FlagH != 0) },
+	"cf": func(c *cpu.CPU) int { return btoi(c.F&cpu.FlagC != 0) },
+}
+
+func (r registerExpr) Eval(c *cpu.CPU, _ *memory.Memory) (int, error) {
+	return registers[string(r)](c), nil
+}
+
+type derefExpr struct {
+	addr Expr
+}
+
+func (d derefExpr) Eval(c *cpu.CPU, mem *memory.Memory) (int, error) {
+	addr, err := d.addr.Eval(c, mem)
+	if err != nil {
+		return 0, err
+	}
+	if addr < 0 || addr > 0xFFFF {
+		return 0, fmt.Errorf("address %X out of range", addr)
+	}
+	return int(mem.Peek(uint16(addr))), nil // Peek, so watches do not disturb the bus
+}
+
+// bankExpr yields the ROM bank mapped at 0x4000-0x7FFF. There is no MBC
+// support yet, so this is always bank 1.
+type bankExpr struct{}
+
+func (bankExpr) Eval(*cpu.CPU, *memory.Memory) (int, error) {
+	return 1, nil
+}
+
+type unaryExpr struct {
+	op      string
+	operand Expr
+}
+
+func (u unaryExpr) Eval(c *cpu.CPU, mem *memory.Memory) (int, error) {
+	v, err := u.operand.Eval(c, mem)
+	if err != nil {
+		return 0, err
+	}
+	switch u.op {
+	case "-":
+		return -v, nil
+	case "!":
+		return btoi(v == 0), nil
+	default: // "~"
+		return ^v, nil
+	}
+}
+
+type binaryExpr struct {
+	op          string
+	left, right Expr
+}
+
+func (b binaryExpr) Eval(c *cpu.CPU, mem *memory.Memory) (int, error) {
+	l, err := b.left.Eval(c, mem)
+	if err != nil {
+		return 0, err
+	}
+	// Short-circuit the logical operators
+	switch {
+	case b.op == "&&" && l == 0:
+		return 0, nil
+	case b.op == "||" && l != 0:
+		return 1, nil
+	}
+	r, err := b.right.Eval(c, mem)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case "&&", "||":
+		return btoi(r != 0), nil
+	case "==":
+		return btoi(l == r), nil
+	case "!=":
+		return btoi(l != r), nil
+	case "<":
+		return btoi(l < r), nil
+	case "<=":
+		return btoi(l <= r), nil
+	case ">":
+		return btoi(l > r), nil
+	case ">=":
+		return btoi(l >= r), nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		if b.op == "/" {
+			return l / r, nil
+		}
+		return l % r, nil
+	case "&":
+		return l & r, nil
+	case "|":
+		return l | r, nil
+	case "^":
+		return l ^ r, nil
+	case "<<":
+		return l << uint(r&31), nil
+	default: // ">>"
+		return l >> uint(r&31), nil
+	}
+}
+
+// Convert boolean to int (0 or 1)
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
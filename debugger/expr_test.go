@@ -0,0 +1,135 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3}, // Left associative
+		{"64 / 4 / 2", 8},
+		{"1 << 2 + 1", 5}, // Shifts bind like multiplication
+		{"$F0 | $0F == $FF", 1},
+		{"$80 & $80 == $80", 1},
+		{"$C0 & $80 == $80", 1},
+		{"$40 & $80 == $80", 0},
+		{"6 ^ 3 == 5", 1},
+		{"1 | 2 ^ 3", 0},   // | and ^ share a level, left to right
+		{"1 + 2 & 3", 3},   // & binds tighter than +
+		{"1 < 2 == 1", 1},  // Comparisons share a level, left to right
+		{"0 || 1 && 0", 0}, // && binds tighter than ||
+		{"1 || 0 && 0", 1},
+		{"-2 * -3", 6},
+		{"!0 + ~0", 0},
+		{"7 % 4 * 2", 6},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.src, cpu.NewCPU(cpu.DMG), nil)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"(1", `expected ")"`},
+		{"[hl", `expected "]"`},
+		{"1 2", `unexpected "2"`},
+		{"foo", "unknown register"},
+		{"rom()", "unknown function"},
+		{"$G", "bad number"},
+		{"a @ 1", "unexpected character"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestEvalMachineState(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	c := cpu.NewCPU(cpu.DMG)
+	c.A, c.F = 0x12, cpu.FlagZ|cpu.FlagC
+	c.H, c.L = 0xC0, 0x10
+	c.SP, c.PC = 0xDFF0, 0x4123
+	mem.Write(0xC010, 0x5A)
+
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"a", 0x12},
+		{"A == 0x12", 1},
+		{"hl", 0xC010},
+		{"[hl]", 0x5A},
+		{"[hl + 1 - 1] == $5A", 1},
+		{"zf && cf && !nf", 1},
+		{"f & $80 == $80", 1},
+		{"f & $40 == $40", 0},
+		{"pc >= $4000 && bank() == 1", 1},
+		{"sp", 0xDFF0},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.src, c, &mem)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %#x, want %#x", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	c := cpu.NewCPU(cpu.DMG)
+	for _, src := range []string{"1 / 0", "1 % 0", "[$10000]", "[-1]"} {
+		if _, err := Eval(src, c, &mem); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+	// The right-hand side is never evaluated when && or || short-circuits
+	for _, src := range []string{"0 && 1 / 0", "1 || 1 / 0"} {
+		if _, err := Eval(src, c, &mem); err != nil {
+			t.Errorf("%q: %v", src, err)
+		}
+	}
+}
+
+// countingTracer counts bus accesses
+type countingTracer int
+
+func (n *countingTracer) Access(memory.AccessKind, uint16, byte) { *n++ }
+
+func TestDerefDoesNotTouchBus(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	var accesses countingTracer
+	mem.SetTracer(&accesses)
+	if _, err := Eval("[$C000] + [$FF04] + [$FF00]", cpu.NewCPU(cpu.DMG), &mem); err != nil {
+		t.Fatal(err)
+	}
+	if accesses != 0 {
+		t.Errorf("evaluating a dereference made %d bus accesses, want 0", accesses)
+	}
+}
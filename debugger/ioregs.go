@@ -0,0 +1,342 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"clockworkgnome/memory"
+)
+
+// IORegister is a decoded snapshot of a single hardware register
+type IORegister struct {
+	Name   string
+	Addr   uint16
+	Value  byte
+	Fields []IOField
+}
+
+// IOField is one decoded bitfield of an IORegister
+type IOField struct {
+	Name  string
+	Value byte
+	Desc  string // Human readable meaning of Value, if known
+}
+
+// bitField describes where a field lives inside its register
+type bitField struct {
+	name   string
+	shift  uint
+	width  uint
+	values []string // Optional names indexed by field value
+}
+
+type ioRegisterDef struct {
+	name   string
+	addr   uint16
+	fields []bitField
+}
+
+// Shared field layouts
+var (
+	onOff        = []string{"off", "on"}
+	envelopeDir  = []string{"decrease", "increase"}
+	dutyCycles   = []string{"12.5%", "25%", "50%", "75%"}
+	paletteShade = []string{"white", "light gray", "dark gray", "black"}
+
+	paletteFields = []bitField{
+		{"color 0", 0, 2, paletteShade},
+		{"color 1", 2, 2, paletteShade},
+		{"color 2", 4, 2, paletteShade},
+		{"color 3", 6, 2, paletteShade},
+	}
+	interruptFields = []bitField{
+		{"vblank", 0, 1, nil},
+		{"lcd stat", 1, 1, nil},
+		{"timer", 2, 1, nil},
+		{"serial", 3, 1, nil},
+		{"joypad", 4, 1, nil},
+	}
+	lengthDutyFields = []bitField{
+		{"length", 0, 6, nil},
+		{"duty", 6, 2, dutyCycles},
+	}
+	envelopeFields = []bitField{
+		{"sweep pace", 0, 3, nil},
+		{"direction", 3, 1, envelopeDir},
+		{"initial volume", 4, 4, nil},
+	}
+	periodHighFields = []bitField{
+		{"period high", 0, 3, nil},
+		{"length enable", 6, 1, onOff},
+		{"trigger", 7, 1, nil},
+	}
+	waveFields = []bitField{
+		{"second sample", 0, 4, nil},
+		{"first sample", 4, 4, nil}, // The high nibble plays first
+	}
+	hdmaFields = []bitField{
+		{"blocks left", 0, 7, nil},
+		{"mode", 7, 1, []string{"general", "hblank"}},
+	}
+	colorIndexFields = []bitField{
+		{"address", 0, 6, nil},
+		{"auto increment", 7, 1, onOff},
+	}
+	wholeByte = []bitField{{"value", 0, 8, nil}}
+)
+
+// strobeBits are write-only bits that start an action rather than hold a
+// setting, so changing another field must not write them again
+var strobeBits = map[uint16]byte{
+	0xFF14: 0x80, // NR14 trigger
+	0xFF19: 0x80, // NR24 trigger
+	0xFF1E: 0x80, // NR34 trigger
+	0xFF23: 0x80, // NR44 trigger
+}
+
+// ioRegisterDefs lists every documented register in address order. The CGB
+// ones read as unused addresses unless the memory is in CGB mode.
+var ioRegisterDefs = []ioRegisterDef{
+	{"P1", 0xFF00, []bitField{
+		{"right/a", 0, 1, nil},
+		{"left/b", 1, 1, nil},
+		{"up/select", 2, 1, nil},
+		{"down/start", 3, 1, nil},
+		{"select d-pad", 4, 1, nil},
+		{"select buttons", 5, 1, nil},
+	}},
+	{"SB", 0xFF01, wholeByte},
+	{"SC", 0xFF02, []bitField{
+		{"clock select", 0, 1, []string{"external", "internal"}},
+		{"transfer", 7, 1, []string{"idle", "in progress"}},
+	}},
+	{"DIV", 0xFF04, wholeByte},
+	{"TIMA", 0xFF05, wholeByte},
+	{"TMA", 0xFF06, wholeByte},
+	{"TAC", 0xFF07, []bitField{
+		{"clock select", 0, 2, []string{"4096 Hz", "262144 Hz", "65536 Hz", "16384 Hz"}},
+		{"enable", 2, 1, onOff},
+	}},
+	{"IF", 0xFF0F, interruptFields},
+	{"NR10", 0xFF10, []bitField{
+		{"sweep step", 0, 3, nil},
+		{"direction", 3, 1, []string{"increase", "decrease"}},
+		{"sweep pace", 4, 3, nil},
+	}},
+	{"NR11", 0xFF11, lengthDutyFields},
+	{"NR12", 0xFF12, envelopeFields},
+	{"NR13", 0xFF13, []bitField{{"period low", 0, 8, nil}}},
+	{"NR14", 0xFF14, periodHighFields},
+	{"NR21", 0xFF16, lengthDutyFields},
+	{"NR22", 0xFF17, envelopeFields},
+	{"NR23", 0xFF18, []bitField{{"period low", 0, 8, nil}}},
+	{"NR24", 0xFF19, periodHighFields},
+	{"NR30", 0xFF1A, []bitField{{"dac enable", 7, 1, onOff}}},
+	{"NR31", 0xFF1B, []bitField{{"length", 0, 8, nil}}},
+	{"NR32", 0xFF1C, []bitField{{"output level", 5, 2, []string{"mute", "100%", "50%", "25%"}}}},
+	{"NR33", 0xFF1D, []bitField{{"period low", 0, 8, nil}}},
+	{"NR34", 0xFF1E, periodHighFields},
+	{"NR41", 0xFF20, []bitField{{"length", 0, 6, nil}}},
+	{"NR42", 0xFF21, envelopeFields},
+	{"NR43", 0xFF22, []bitField{
+		{"clock divider", 0, 3, nil},
+		{"lfsr width", 3, 1, []string{"15 bit", "7 bit"}},
+		{"clock shift", 4, 4, nil},
+	}},
+	{"NR44", 0xFF23, []bitField{
+		{"length enable", 6, 1, onOff},
+		{"trigger", 7, 1, nil},
+	}},
+	{"NR50", 0xFF24, []bitField{
+		{"right volume", 0, 3, nil},
+		{"vin right", 3, 1, onOff},
+		{"left volume", 4, 3, nil},
+		{"vin left", 7, 1, onOff},
+	}},
+	{"NR51", 0xFF25, []bitField{
+		{"ch1 right", 0, 1, onOff},
+		{"ch2 right", 1, 1, onOff},
+		{"ch3 right", 2, 1, onOff},
+		{"ch4 right", 3, 1, onOff},
+		{"ch1 left", 4, 1, onOff},
+		{"ch2 left", 5, 1, onOff},
+		{"ch3 left", 6, 1, onOff},
+		{"ch4 left", 7, 1, onOff},
+	}},
+	{"NR52", 0xFF26, []bitField{
+		{"ch1 on", 0, 1, onOff},
+		{"ch2 on", 1, 1, onOff},
+		{"ch3 on", 2, 1, onOff},
+		{"ch4 on", 3, 1, onOff},
+		{"audio enable", 7, 1, onOff},
+	}},
+	{"WAV0", 0xFF30, waveFields},
+	{"WAV1", 0xFF31, waveFields},
+	{"WAV2", 0xFF32, waveFields},
+	{"WAV3", 0xFF33, waveFields},
+	{"WAV4", 0xFF34, waveFields},
+	{"WAV5", 0xFF35, waveFields},
+	{"WAV6", 0xFF36, waveFields},
+	{"WAV7", 0xFF37, waveFields},
+	{"WAV8", 0xFF38, waveFields},
+	{"WAV9", 0xFF39, waveFields},
+	{"WAVA", 0xFF3A, waveFields},
+	{"WAVB", 0xFF3B, waveFields},
+	{"WAVC", 0xFF3C, waveFields},
+	{"WAVD", 0xFF3D, waveFields},
+	{"WAVE", 0xFF3E, waveFields},
+	{"WAVF", 0xFF3F, waveFields},
+	{"LCDC", 0xFF40, []bitField{
+		{"bg/window enable", 0, 1, onOff},
+		{"obj enable", 1, 1, onOff},
+		{"obj size", 2, 1, []string{"8x8", "8x16"}},
+		{"bg tile map", 3, 1, []string{"9800", "9C00"}},
+		{"bg/window tiles", 4, 1, []string{"8800", "8000"}},
+		{"window enable", 5, 1, onOff},
+		{"window tile map", 6, 1, []string{"9800", "9C00"}},
+		{"lcd enable", 7, 1, onOff},
+	}},
+	{"STAT", 0xFF41, []bitField{
+		{"mode", 0, 2, []string{"hblank", "vblank", "oam scan", "drawing"}},
+		{"lyc == ly", 2, 1, nil},
+		{"hblank int", 3, 1, onOff},
+		{"vblank int", 4, 1, onOff},
+		{"oam int", 5, 1, onOff},
+		{"lyc int", 6, 1, onOff},
+	}},
+	{"SCY", 0xFF42, wholeByte},
+	{"SCX", 0xFF43, wholeByte},
+	{"LY", 0xFF44, wholeByte},
+	{"LYC", 0xFF45, wholeByte},
+	{"DMA", 0xFF46, []bitField{{"source page", 0, 8, nil}}},
+	{"BGP", 0xFF47, paletteFields},
+	{"OBP0", 0xFF48, paletteFields},
+	{"OBP1", 0xFF49, paletteFields},
+	{"WY", 0xFF4A, wholeByte},
+	{"WX", 0xFF4B, wholeByte},
+	{"KEY1", 0xFF4D, []bitField{
+		{"switch armed", 0, 1, []string{"no", "yes"}},
+		{"speed", 7, 1, []string{"normal", "double"}},
+	}},
+	{"VBK", 0xFF4F, []bitField{{"vram bank", 0, 1, nil}}},
+	{"BOOT", 0xFF50, []bitField{{"unmap", 0, 8, nil}}}, // Any non-zero write unmaps the boot ROM
+	{"HDMA1", 0xFF51, []bitField{{"source high", 0, 8, nil}}},
+	{"HDMA2", 0xFF52, []bitField{{"source low", 0, 8, nil}}},
+	{"HDMA3", 0xFF53, []bitField{{"dest high", 0, 8, nil}}},
+	{"HDMA4", 0xFF54, []bitField{{"dest low", 0, 8, nil}}},
+	{"HDMA5", 0xFF55, hdmaFields},
+	{"RP", 0xFF56, []bitField{
+		{"write", 0, 1, []string{"off", "on"}},
+		{"receiving", 1, 1, []string{"yes", "no"}}, // Active low
+		{"read enable", 6, 2, []string{"off", "", "", "on"}},
+	}},
+	{"BCPS", 0xFF68, colorIndexFields},
+	{"BCPD", 0xFF69, wholeByte},
+	{"OCPS", 0xFF6A, colorIndexFields},
+	{"OCPD", 0xFF6B, wholeByte},
+	{"OPRI", 0xFF6C, []bitField{{"priority", 0, 1, []string{"oam index", "x position"}}}},
+	{"SVBK", 0xFF70, []bitField{{"wram bank", 0, 3, nil}}},
+	{"IE", 0xFFFF, interruptFields},
+}
+
+// IORegisters decodes every known hardware register from memory. It peeks,
+// so decoding has no side effects on the bus.
+func IORegisters(mem *memory.Memory) []IORegister {
+	regs := make([]IORegister, 0, len(ioRegisterDefs))
+	for _, def := range ioRegisterDefs {
+		regs = append(regs, decodeRegister(def, mem.Peek(def.addr)))
+	}
+	return regs
+}
+
+// IORegisterByName decodes a single register, e.g. "LCDC" or "tac"
+func IORegisterByName(mem *memory.Memory, name string) (IORegister, error) {
+	def, err := lookupRegister(name)
+	if err != nil {
+		return IORegister{}, err
+	}
+	return decodeRegister(def, mem.Peek(def.addr)), nil
+}
+
+// WriteIORegister writes a raw value to a register by name so its effect can
+// be experimented with from the debugger
+func WriteIORegister(mem *memory.Memory, name string, value byte) error {
+	def, err := lookupRegister(name)
+	if err != nil {
+		return err
+	}
+	mem.Write(def.addr, value)
+	return nil
+}
+
+// WriteIOField updates a single bitfield of a register, leaving the other
+// bits as they were last written
+func WriteIOField(mem *memory.Memory, name, field string, value byte) error {
+	def, err := lookupRegister(name)
+	if err != nil {
+		return err
+	}
+	for _, f := range def.fields {
+		if !strings.EqualFold(f.name, field) {
+			continue
+		}
+		mask := byte((1<<f.width)-1) << f.shift
+		if value > byte((1<<f.width)-1) {
+			return fmt.Errorf("value %d does not fit in %s.%s (%d bits)", value, def.name, f.name, f.width)
+		}
+		old := rawRegister(mem, def.addr) &^ strobeBits[def.addr]
+		mem.Write(def.addr, old&^mask|value<<f.shift)
+		return nil
+	}
+	return fmt.Errorf("register %s has no field %q", def.name, field)
+}
+
+// rawRegister returns the bits last written to a register. Write-only bits
+// read back as 1 over the bus, so writing a read value back would change them.
+func rawRegister(mem *memory.Memory, addr uint16) byte {
+	if addr >= memory.IOPortsStart && addr <= memory.IOPortsEnd {
+		return mem.PeekIO(addr)
+	}
+	return mem.Peek(addr)
+}
+
+func lookupRegister(name string) (ioRegisterDef, error) {
+	for _, def := range ioRegisterDefs {
+		if strings.EqualFold(def.name, name) {
+			return def, nil
+		}
+	}
+	return ioRegisterDef{}, fmt.Errorf("unknown I/O register %q", name)
+}
+
+func decodeRegister(def ioRegisterDef, value byte) IORegister {
+	reg := IORegister{Name: def.name, Addr: def.addr, Value: value}
+	for _, f := range def.fields {
+		v := (value >> f.shift) & byte((1<<f.width)-1)
+		field := IOField{Name: f.name, Value: v}
+		if int(v) < len(f.values) {
+			field.Desc = f.values[v]
+		}
+		reg.Fields = append(reg.Fields, field)
+	}
+	return reg
+}
+
+// String renders the register as a single line, e.g.
+// "LCDC FF40 = 91 [bg/window enable=on obj enable=off ...]"
+func (r IORegister) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-4s %04X = %02X [", r.Name, r.Addr, r.Value)
+	for i, f := range r.Fields {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if f.Desc != "" {
+			fmt.Fprintf(&sb, "%s=%s", f.Name, f.Desc)
+		} else {
+			fmt.Fprintf(&sb, "%s=%d", f.Name, f.Value)
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
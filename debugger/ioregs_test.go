@@ -0,0 +1,86 @@
+package debugger
+
+import (
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+func TestIORegisterDecode(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	mem.SetCGBMode(true)
+	mem.Write(0xFF40, 0x91)
+	mem.Write(0xFF30, 0x4C)
+	mem.Write(memory.KEY1Addr, 0x01)
+
+	tests := []struct {
+		name, want string
+	}{
+		{"LCDC", "LCDC FF40 = 91 [bg/window enable=on obj enable=off obj size=8x8 bg tile map=9800 bg/window tiles=8000 window enable=off window tile map=9800 lcd enable=on]"},
+		{"wav0", "WAV0 FF30 = 4C [second sample=12 first sample=4]"},
+		{"KEY1", "KEY1 FF4D = 7F [switch armed=yes speed=normal]"},
+		{"BOOT", "BOOT FF50 = FF [unmap=255]"},
+	}
+	for _, tt := range tests {
+		reg, err := IORegisterByName(&mem, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reg.String(); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := IORegisterByName(&mem, "NR15"); err == nil {
+		t.Error("unknown register decoded")
+	}
+}
+
+func TestIORegistersDoNotTouchBus(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	var accesses countingTracer
+	mem.SetTracer(&accesses)
+	regs := IORegisters(&mem)
+	if accesses != 0 {
+		t.Errorf("decoding made %d bus accesses, want 0", accesses)
+	}
+	for i := 1; i < len(regs); i++ {
+		if regs[i].Addr <= regs[i-1].Addr {
+			t.Errorf("%s listed after %s", regs[i].Name, regs[i-1].Name)
+		}
+	}
+}
+
+func TestWriteIOFieldKeepsWriteOnlyBits(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+
+	// NR11 length reads back as 1s, changing the duty must keep it
+	mem.Write(0xFF11, 0x85) // Duty 50%, length 5
+	if err := WriteIOField(&mem, "NR11", "duty", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := mem.PeekIO(0xFF11); got != 0x45 {
+		t.Errorf("NR11 = %02X, want 45", got)
+	}
+
+	// Changing the period must not trigger NR14 again
+	mem.Write(0xFF14, 0xC5) // Trigger, length enable, period high 5
+	if err := WriteIOField(&mem, "nr14", "period high", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := mem.PeekIO(0xFF14); got != 0x42 {
+		t.Errorf("NR14 = %02X, want 42", got)
+	}
+	if err := WriteIOField(&mem, "NR14", "trigger", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := mem.PeekIO(0xFF14); got != 0xC2 {
+		t.Errorf("NR14 after trigger = %02X, want C2", got)
+	}
+
+	if err := WriteIOField(&mem, "NR11", "duty", 4); err == nil {
+		t.Error("oversized value accepted")
+	}
+	if err := WriteIOField(&mem, "NR11", "volume", 1); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package debugger
+
+import (
+	"fmt"
+	"io"
+
+	"clockworkgnome/memory"
+)
+
+// Background registers and layout
+const (
+	lcdcAddr uint16 = 0xFF40 // LCDC, bit 3 selects the BG map and bit 4 the tile data
+	bgpAddr  uint16 = 0xFF47 // BGP, maps color indexes to shades
+
+	bgMapSize = 32 * 32 // Tiles in a background map
+	tileSize  = 16      // Bytes per 8x8 2bpp tile
+)
+
+// dmgShades are the gray levels of the four DMG shades, lightest first
+var dmgShades = [4]byte{0xFF, 0xAA, 0x55, 0x00}
+
+// ExportBGMap writes the 32x32 background map currently selected by LCDC as
+// one tile index byte per tile in row order, the plain .tilemap layout that
+// editors such as Tilemap Studio import. The indexes address the tiles
+// written by ExportBGTiles.
+func ExportBGMap(mem *memory.Memory, w io.Writer) error {
+	base := memory.VRAMStart + 0x1800 // 0x9800
+	if mem.Peek(lcdcAddr)&0x08 != 0 {
+		base = memory.VRAMStart + 0x1C00 // 0x9C00
+	}
+	tilemap := make([]byte, bgMapSize)
+	for i := range tilemap {
+		tilemap[i] = mem.Peek(base + uint16(i))
+	}
+	_, err := w.Write(tilemap)
+	return err
+}
+
+// ExportBGTiles writes the 256 tiles the background can address in the
+// native 2bpp format (.2bpp), ordered by tile index. With signed addressing
+// (LCDC bit 4 clear) index 0 is the tile at 0x9000 and index 128 the one at
+// 0x8800, so map indexes can be used as-is.
+func ExportBGTiles(mem *memory.Memory, w io.Writer) error {
+	unsigned := mem.Peek(lcdcAddr)&0x10 != 0
+	tiles := make([]byte, 256*tileSize)
+	for index := 0; index < 256; index++ {
+		addr := int(memory.VRAMStart) + index*tileSize
+		if !unsigned {
+			addr = 0x9000 + int(int8(index))*tileSize
+		}
+		for i := 0; i < tileSize; i++ {
+			tiles[index*tileSize+i] = mem.Peek(uint16(addr + i))
+		}
+	}
+	_, err := w.Write(tiles)
+	return err
+}
+
+// ExportBGPalette writes the background palette from BGP as a JASC-PAL file
+// of four gray levels. DMG has no per-tile attributes, so this is the only
+// color information a map needs.
+func ExportBGPalette(mem *memory.Memory, w io.Writer) error {
+	bgp := mem.Peek(bgpAddr)
+	if _, err := fmt.Fprint(w, "JASC-PAL\r\n0100\r\n4\r\n"); err != nil {
+		return err
+	}
+	for color := 0; color < 4; color++ {
+		shade := dmgShades[bgp>>(2*color)&0x03]
+		if _, err := fmt.Fprintf(w, "%d %d %d\r\n", shade, shade, shade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package debugger
+
+import (
+	"bytes"
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+func TestExportBGMapSelect(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	mem.Write(0x9800, 0x11)
+	mem.Write(0x9BFF, 0x22)
+	mem.Write(0x9C00, 0x33)
+	mem.Write(0x9FFF, 0x44)
+	for _, tt := range []struct {
+		lcdc        byte
+		first, last byte
+	}{
+		{0x91, 0x11, 0x22}, // Bit 3 clear, 0x9800
+		{0x99, 0x33, 0x44}, // Bit 3 set, 0x9C00
+	} {
+		mem.PokeIO(lcdcAddr, tt.lcdc)
+		var out bytes.Buffer
+		if err := ExportBGMap(&mem, &out); err != nil {
+			t.Fatal(err)
+		}
+		got := out.Bytes()
+		if len(got) != bgMapSize || got[0] != tt.first || got[bgMapSize-1] != tt.last {
+			t.Errorf("LCDC %02X: %d bytes from %02X to %02X, want %d from %02X to %02X",
+				tt.lcdc, len(got), got[0], got[len(got)-1], bgMapSize, tt.first, tt.last)
+		}
+	}
+}
+
+func TestExportBGTilesAddressing(t *testing.T) {
+	mem := memory.NewMemory(make([]byte, 0x8000))
+	// Tag the first byte of each tile the indexes can land on
+	tags := map[uint16]byte{0x8000: 0xA0, 0x8800: 0xA8, 0x8FF0: 0xAF, 0x9000: 0xB0}
+	for addr, tag := range tags {
+		mem.Write(addr, tag)
+	}
+	for _, tt := range []struct {
+		lcdc byte
+		want map[int]uint16 // Tile index to the address it comes from
+	}{
+		{0x81, map[int]uint16{0: 0x9000, 128: 0x8800, 255: 0x8FF0}}, // Bit 4 clear, signed
+		{0x91, map[int]uint16{0: 0x8000, 128: 0x8800, 255: 0x8FF0}}, // Bit 4 set, unsigned
+	} {
+		mem.PokeIO(lcdcAddr, tt.lcdc)
+		var out bytes.Buffer
+		if err := ExportBGTiles(&mem, &out); err != nil {
+			t.Fatal(err)
+		}
+		got := out.Bytes()
+		if len(got) != 256*tileSize {
+			t.Fatalf("LCDC %02X: %d bytes, want %d", tt.lcdc, len(got), 256*tileSize)
+		}
+		for index, addr := range tt.want {
+			if got[index*tileSize] != tags[addr] {
+				t.Errorf("LCDC %02X: tile %d starts %02X, want %02X from %04X",
+					tt.lcdc, index, got[index*tileSize], tags[addr], addr)
+			}
+		}
+	}
+}
+
+func TestExportBGPalette(t *testing.T) {
+	for _, tt := range []struct {
+		bgp  byte
+		want string
+	}{
+		{0xE4, "JASC-PAL\r\n0100\r\n4\r\n255 255 255\r\n170 170 170\r\n85 85 85\r\n0 0 0\r\n"},
+		{0x1B, "JASC-PAL\r\n0100\r\n4\r\n0 0 0\r\n85 85 85\r\n170 170 170\r\n255 255 255\r\n"},
+	} {
+		mem := memory.NewMemory(make([]byte, 0x8000))
+		mem.PokeIO(bgpAddr, tt.bgp)
+		var out bytes.Buffer
+		if err := ExportBGPalette(&mem, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("BGP %02X: got %q, want %q", tt.bgp, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package headless
+
+import (
+	"bytes"
+	"sync"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+)
+
+// Job describes one headless run
+type Job struct {
+	ROM    []byte   // Cartridge image
+	Steps  int      // Number of instructions to execute
+	Probes []uint16 // Addresses to sample once the run finishes
+}
+
+// Result is what a finished Job produced
+type Result struct {
+	Job    int             // Index of the job in the RunMany input
+	Cycles int             // Cycles consumed by the CPU
+	PC     uint16          // Program counter at the end of the run
+	Serial []byte          // Everything the game sent over the serial port
+	Probes map[uint16]byte // Sampled memory, keyed by address
+	Fault  *cpu.Fault      // Why the run stopped early, if it did
+	Err    error           // Error that stopped the CPU, such as an illegal opcode
+}
+
+// Run executes a single job on a fresh machine
+func Run(job Job) Result {
+	var serial bytes.Buffer
+	mem := memory.NewMemory(job.ROM)
+	mem.SetSerialOutput(&serial)
+	cpu.DMG.InitIO(&mem)
+	c := cpu.NewCPU(cpu.DMG)
+	c.SetIllegalOpcodeMode(cpu.IllegalOpcodeReport)
+
+	var fault *cpu.Fault
+	c.SetWatchdog(cpu.NewWatchdog(func(f cpu.Fault) {
+		if fault == nil {
+			fault = &f
+		}
+	}))
+	for i := 0; i < job.Steps && fault == nil && c.Err() == nil; i++ {
+		c.Execute(&mem)
+	}
+
+	result := Result{
+		Cycles: c.Cycles,
+		PC:     c.PC,
+		Serial: serial.Bytes(),
+		Probes: make(map[uint16]byte, len(job.Probes)),
+		Fault:  fault,
+		Err:    c.Err(),
+	}
+	for _, addr := range job.Probes {
+		result.Probes[addr] = mem.Peek(addr) // Sampling is not bus activity
+	}
+	return result
+}
+
+// RunMany spreads jobs over a pool of workers, each running its own
+// emulator instance, and returns the results in job order
+func RunMany(jobs []Job, workers int) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(jobs))
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = Run(jobs[i])
+				results[i].Job = i
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
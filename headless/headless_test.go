@@ -0,0 +1,45 @@
+package headless
+
+import "testing"
+
+// storeROM returns a cartridge that stores value at C000 and then loops
+func storeROM(value byte) []byte {
+	rom := make([]byte, 0x4000) // Half the ROM area, so 4000-7FFF maps nothing
+	copy(rom[0x0100:], []byte{
+		0x3E, value, // LD A,value
+		0xEA, 0x00, 0xC0, // LD ($C000),A
+		0x18, 0xFE, // JR $
+	})
+	return rom
+}
+
+func TestRunProbes(t *testing.T) {
+	result := Run(Job{ROM: storeROM(0x42), Steps: 10, Probes: []uint16{0xC000, 0x4000}})
+	if result.Fault != nil || result.Err != nil {
+		t.Fatalf("run stopped: %v %v", result.Fault, result.Err)
+	}
+	if result.PC != 0x0105 {
+		t.Errorf("PC = %04X, want 0105", result.PC)
+	}
+	if result.Probes[0xC000] != 0x42 || result.Probes[0x4000] != 0xFF {
+		t.Errorf("probes = %v", result.Probes)
+	}
+
+	// Sampling is not part of the run, so probes never change its outcome
+	again := Run(Job{ROM: storeROM(0x42), Steps: 10})
+	if again.Cycles != result.Cycles {
+		t.Errorf("%d cycles with probes, %d without", result.Cycles, again.Cycles)
+	}
+}
+
+func TestRunManyKeepsOrder(t *testing.T) {
+	jobs := make([]Job, 16)
+	for i := range jobs {
+		jobs[i] = Job{ROM: storeROM(byte(i)), Steps: 5, Probes: []uint16{0xC000}}
+	}
+	for i, result := range RunMany(jobs, 4) {
+		if result.Job != i || result.Probes[0xC000] != byte(i) {
+			t.Errorf("result %d: job %d stored %02X", i, result.Job, result.Probes[0xC000])
+		}
+	}
+}
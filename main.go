@@ -1,40 +1,287 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"time"
 
-	cpuPkg "clockworkgnome/cpu"    // Adjust this import to match your project structure
+	cpuPkg "clockworkgnome/cpu" // Adjust this import to match your project structure
+	debugPkg "clockworkgnome/debugger"
 	memPkg "clockworkgnome/memory" // Adjust this import to match your project structure
+	tracePkg "clockworkgnome/trace"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <path_to_rom>")
+	dumpBusPath := flag.String("dump-bus", "", "write the 64KB bus image to this file when emulation ends")
+	dumpBGPrefix := flag.String("dump-bg", "", "write the background map, tiles and palette to PREFIX.tilemap, .2bpp and .pal when emulation ends")
+	loadBusPath := flag.String("load-bus", "", "boot a scratch machine from a 64KB bus image instead of a ROM")
+	blank := flag.Bool("blank", false, "boot a blank machine with no cartridge inserted")
+	bootROMPath := flag.String("boot-rom", "", "map this boot ROM over 0x0000-0x00FF until it disables itself")
+	fastBoot := flag.Bool("fast-boot", false, "run the boot ROM silently instead of tracing it")
+	serialStdout := flag.Bool("serial-stdout", false, "print bytes sent over the serial port to stdout")
+	linkName := flag.String("link", "disconnected", "link cable partner: disconnected, loopback, or a script file of reply bytes")
+	busTracePath := flag.String("bus-trace", "", "record bus accesses to this file in Chrome/Perfetto trace format")
+	busTraceSample := flag.Int("bus-trace-sample", 1, "record only every Nth bus access")
+	busTraceRange := flag.String("bus-trace-range", "0000-FFFF", "only record bus accesses within this address range")
+	modelName := flag.String("model", "dmg", "hardware model to emulate: dmg, mgb, sgb or cgb")
+	stackGuard := flag.Bool("stack-guard", false, "stop when the stack overflows or underflows the region the game set it up in")
+	ramSeed := flag.Int64("ram-seed", 0, "fill RAM with pseudo-random bytes from this seed at power on (-1 picks a new seed each run, 0 leaves RAM zeroed)")
+	checkpointsPath := flag.String("checkpoints", "", "capture a checkpoint whenever a trigger in this file (one \"name = expression\" per line) changes value")
+	rewindOn := flag.String("rewind-on", "", "rewind to the last checkpoint each time this expression becomes true, e.g. \"[$D16C] == 0\"")
+	debugPortAddr := flag.Uint("debug-port", 0, "log text written to this address (e.g. 0xFF7F) as a virtual console")
+	var pokes pokeList
+	flag.Var(&pokes, "poke", "store hex bytes at an address before running, e.g. C000=3E42EA00D076 (repeatable). With -blank and no boot ROM, execution starts at the first one.")
+	flag.Parse()
+
+	if flag.Arg(0) == "opcodes" {
+		printOpcodes(flag.Args()[1:]) // Instruction metadata for external tools
+		return
+	}
+	if flag.Arg(0) == "testrom" {
+		runTestROM(flag.Args()[1:]) // Opcode validation against the reference ALU
+		return
+	}
+	if flag.Arg(0) == "sm83" {
+		runSM83(flag.Args()[1:]) // Community single-step test vectors
+		return
+	}
+
+	var (
+		mem     memPkg.Memory
+		ROMData []byte
+	)
+	switch {
+	case *loadBusPath != "":
+		// Restore a scratch machine from a raw bus image
+		f, err := os.Open(*loadBusPath)
+		if err != nil {
+			fmt.Printf("Failed to open bus image: %v\n", err)
+			return
+		}
+		mem, err = memPkg.LoadBus(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Failed to load bus image: %v\n", err)
+			return
+		}
+		ROMData = make([]byte, memPkg.ROMEnd+1) // The image always maps the full ROM area
+	case flag.NArg() > 0:
+		// Load ROM data from file
+		var err error
+		ROMData, err = ioutil.ReadFile(flag.Arg(0))
+		if err != nil {
+			fmt.Printf("Failed to load ROM: %v\n", err)
+			return
+		}
+		mem = memPkg.NewMemory(ROMData) // Initialize memory with ROM data
+	case *blank:
+		// RAM-only machine. There is nothing to run in it but a boot ROM,
+		// which runs until it reaches the cartridge entry point, or poked
+		// code, which runs until it halts for good.
+		if *bootROMPath == "" && len(pokes) == 0 {
+			fmt.Println("-blank needs a boot ROM (-boot-rom) or code to run (-poke)")
+			return
+		}
+		mem = memPkg.NewMemory(nil)
+		ROMData = make([]byte, 0x0100)
+	default:
+		fmt.Println("Usage: go run main.go [flags] <path_to_rom | -blank -poke ADDR=BYTES | -blank -boot-rom FILE | -load-bus image>")
+		fmt.Println("       go run main.go opcodes [-json]")
+		flag.PrintDefaults()
+		return
+	}
+
+	if *ramSeed != 0 && *loadBusPath == "" {
+		// Uninitialized RAM, reproducible through the printed seed
+		seed := *ramSeed
+		if seed == -1 {
+			seed = time.Now().UnixNano()
+		}
+		fmt.Printf("RAM seed: %d\n", seed)
+		mem.RandomizeRAM(rand.New(rand.NewSource(seed)))
+	}
+
+	if *bootROMPath != "" {
+		// Load the optional boot ROM
+		bootROM, err := ioutil.ReadFile(*bootROMPath)
+		if err != nil {
+			fmt.Printf("Failed to load boot ROM: %v\n", err)
+			return
+		}
+		mem.SetBootROM(bootROM)
+	}
+
+	mem.SetLogOutput(os.Stdout) // Report invalid accesses on the console
+	if *serialStdout {
+		// Test ROMs and homebrew print through the link cable
+		mem.SetSerialOutput(os.Stdout)
+	}
+	switch *linkName {
+	case "disconnected":
+		mem.SetLinkEndpoint(memPkg.Disconnected{})
+	case "loopback":
+		mem.SetLinkEndpoint(memPkg.Loopback{})
+	default:
+		// Scripted partner for exercising link-cable code
+		f, err := os.Open(*linkName)
+		if err != nil {
+			fmt.Printf("Failed to open link script: %v\n", err)
+			return
+		}
+		script, err := memPkg.ParseScript(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Failed to parse link script: %v\n", err)
+			return
+		}
+		mem.SetLinkEndpoint(script)
+	}
+	if *debugPortAddr > 0xFFFF {
+		fmt.Printf("Invalid debug port address: %X\n", *debugPortAddr)
 		return
 	}
 
-	// Load ROM data from file
-	romPath := os.Args[1]
-	ROMData, err := ioutil.ReadFile(romPath)
+	model, err := cpuPkg.ParseModel(*modelName)
 	if err != nil {
-		fmt.Printf("Failed to load ROM: %v\n", err)
+		fmt.Println(err)
 		return
 	}
+	mem.SetCGBMode(model == cpuPkg.CGB)
+	if *bootROMPath == "" {
+		model.InitIO(&mem) // Start from the state the boot ROM would leave
+	}
+
+	for _, p := range pokes {
+		for i, b := range p.data {
+			mem.Write(p.addr+uint16(i), b)
+		}
+	}
+	playground := *blank && *bootROMPath == "" // Running poked code
 
 	fmt.Println("Starting Game Boy Emulator...")
 
-	// Initialize the memory and CPU with loaded ROM data
-	mem := memPkg.NewMemory(ROMData) // Initialize memory with ROM data
-	cpu := cpuPkg.NewCPU()           // Create a new CPU instance
+	// Initialize the CPU
+	cpu := cpuPkg.NewCPU(model) // Create a new CPU instance
+	cpu.SetLogOutput(os.Stdout)
+	cpu.SetIllegalOpcodeMode(cpuPkg.IllegalOpcodeReport)
+
+	if *debugPortAddr != 0 {
+		// Homebrew logging through a spare address
+		mem.SetDebugPort(uint16(*debugPortAddr), os.Stdout, func() int { return cpu.Cycles })
+	}
+
+	if *busTracePath != "" {
+		// Record bus activity for a timeline viewer
+		var start, end uint16
+		if _, err := fmt.Sscanf(*busTraceRange, "%x-%x", &start, &end); err != nil {
+			fmt.Printf("Invalid bus trace range %q: %v\n", *busTraceRange, err)
+			return
+		}
+		f, err := os.Create(*busTracePath)
+		if err != nil {
+			fmt.Printf("Failed to create bus trace: %v\n", err)
+			return
+		}
+		defer f.Close()
+		tracer := tracePkg.NewChromeTracer(f, func() int { return cpu.Cycles },
+			func() int { return cpu.Timing().ClockHz })
+		tracer.SampleEvery = *busTraceSample
+		tracer.Start, tracer.End = start, end
+		mem.SetTracer(tracer)
+		defer func() {
+			if err := tracer.Close(); err != nil {
+				fmt.Printf("Failed to write bus trace: %v\n", err)
+			}
+		}()
+	}
+
+	// Stop instead of spinning forever on runaway code
+	var fault *cpuPkg.Fault
+	watchdog := cpuPkg.NewWatchdog(func(f cpuPkg.Fault) {
+		if fault == nil {
+			fault = &f
+		}
+	})
+	watchdog.GuardStack = *stackGuard
+	cpu.SetWatchdog(watchdog)
+
+	// Practice checkpoints, captured and restored by expression
+	var (
+		checkpoints *debugPkg.Checkpoints
+		rewindExpr  debugPkg.Expr
+		rewindHeld  bool // Whether rewindExpr was true after the previous instruction
+	)
+	if *checkpointsPath != "" {
+		f, err := os.Open(*checkpointsPath)
+		if err != nil {
+			fmt.Printf("Failed to open checkpoint triggers: %v\n", err)
+			return
+		}
+		checkpoints, err = debugPkg.ParseCheckpoints(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Failed to parse checkpoint triggers: %v\n", err)
+			return
+		}
+	}
+	if *rewindOn != "" {
+		if checkpoints == nil {
+			fmt.Println("-rewind-on needs -checkpoints")
+			return
+		}
+		rewindExpr, err = debugPkg.Parse(*rewindOn)
+		if err != nil {
+			fmt.Printf("Invalid rewind expression: %v\n", err)
+			return
+		}
+	}
 
-	// Set the Program Counter to the start of ROM
-	cpu.PC = 0x0000 // Start execution from the beginning of the ROM
+	if mem.BootROMMapped() {
+		cpu.PC = 0x0000 // The boot ROM starts from power-on, not post-boot state
+	}
+	if playground {
+		cpu.PC = pokes[0].addr
+	}
 
 	// Main emulation loop
 	for {
-		cpu.Execute(mem) // Execute the next instruction
+		cpu.Execute(&mem) // Execute the next instruction
+
+		if checkpoints != nil {
+			name, err := checkpoints.Update(cpu, &mem)
+			if err != nil {
+				fmt.Printf("Checkpoint failed: %v\n", err)
+				break
+			}
+			if name != "" {
+				fmt.Printf("Checkpoint: %s\n", name)
+			}
+		}
+		if rewindExpr != nil {
+			// Rewind on the edge only, so a condition that still holds
+			// after the jump back does not rewind again
+			v, err := rewindExpr.Eval(cpu, &mem)
+			if err != nil {
+				fmt.Printf("Rewind expression failed: %v\n", err)
+				break
+			}
+			if v != 0 && !rewindHeld {
+				if err := checkpoints.Rewind(cpu, &mem); err != nil {
+					fmt.Printf("Rewind skipped: %v\n", err)
+				} else {
+					fmt.Printf("Rewound to checkpoint %s\n", checkpoints.Last())
+				}
+			}
+			rewindHeld = v != 0
+		}
+
+		if *fastBoot && mem.BootROMMapped() {
+			continue // Skip the register dump until the boot ROM hands over
+		}
 
 		// Print CPU Registers and Flags after execution
 		fmt.Printf("A: %d (0x%02X)\n", cpu.A, cpu.A)
@@ -53,10 +300,68 @@ func main() {
 			cpu.F&cpuPkg.FlagC != 0,
 		)
 
+		if fault != nil {
+			fmt.Printf("Emulation fault: %v\n", fault)
+			break
+		}
+		if err := cpu.Err(); err != nil {
+			fmt.Printf("CPU stopped: %v\n", err)
+			break
+		}
+
+		if playground {
+			// A snippet ends with HALT and nothing enabled to wake it
+			if cpu.Halted && mem.Peek(memPkg.IEAddr)&0x1F == 0 {
+				fmt.Println("Halted, ending emulation loop.")
+				break
+			}
+			continue
+		}
+
 		// Simple exit condition
 		if cpu.PC >= uint16(len(ROMData)) { // Check if PC exceeds ROM data size
 			fmt.Println("Ending emulation loop.")
 			break // Exit the emulation loop
 		}
 	}
+
+	if *dumpBusPath != "" {
+		// Save the final bus image for other reverse-engineering tools
+		f, err := os.Create(*dumpBusPath)
+		if err != nil {
+			fmt.Printf("Failed to create bus image: %v\n", err)
+			return
+		}
+		defer f.Close()
+		if err := mem.DumpBus(f); err != nil {
+			fmt.Printf("Failed to write bus image: %v\n", err)
+		}
+	}
+
+	if *dumpBGPrefix != "" {
+		// Export the background for map editors
+		exports := []struct {
+			ext    string
+			export func(*memPkg.Memory, io.Writer) error
+		}{
+			{".tilemap", debugPkg.ExportBGMap},
+			{".2bpp", debugPkg.ExportBGTiles},
+			{".pal", debugPkg.ExportBGPalette},
+		}
+		for _, e := range exports {
+			f, err := os.Create(*dumpBGPrefix + e.ext)
+			if err != nil {
+				fmt.Printf("Failed to create background export: %v\n", err)
+				return
+			}
+			err = e.export(&mem, f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				fmt.Printf("Failed to write background export: %v\n", err)
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,57 @@
+package memory
+
+// Ticker is hardware clocked alongside the CPU, such as the PPU or timer
+type Ticker interface {
+	Tick(cycles int)
+}
+
+// AddTicker attaches hardware clocked by the CPU, such as the timer, serial
+// port and OAM DMA. These run twice as fast in CGB double-speed mode.
+func (m *Memory) AddTicker(t Ticker) {
+	m.tickers = append(m.tickers, t)
+}
+
+// AddNormalSpeedTicker attaches hardware that keeps its pace when a CGB
+// switches to double speed, which is the PPU and the APU
+func (m *Memory) AddNormalSpeedTicker(t Ticker) {
+	m.normalSpeedTickers = append(m.normalSpeedTickers, t)
+}
+
+// Tick advances every attached component by the given number of CPU
+// T-cycles. In double-speed mode the normal-speed components get half as
+// many.
+func (m *Memory) Tick(cycles int, doubleSpeed bool) {
+	for _, t := range m.tickers {
+		t.Tick(cycles)
+	}
+	if doubleSpeed {
+		cycles /= 2
+	}
+	for _, t := range m.normalSpeedTickers {
+		t.Tick(cycles)
+	}
+}
+
+// SetAccessClock installs a function called before every Read, Write and
+// Fetch completes, so a stepping CPU can tick the hardware one M-cycle per
+// bus access. A nil fn removes it.
+func (m *Memory) SetAccessClock(fn func()) {
+	m.accessClock = fn
+}
+
+// Idle marks an M-cycle an instruction spends without using the bus, such as
+// the 16-bit adder settling, so a stepping CPU ticks the hardware for it in
+// order with the accesses around it
+func (m *Memory) Idle() {
+	if m.accessClock != nil {
+		m.accessClock()
+	}
+}
+
+// Peek reads an address without tracing, clocking or counting it as an
+// invalid access, for state the hardware looks up internally rather than
+// over the bus
+func (m *Memory) Peek(addr uint16) byte {
+	value, _ := m.read(addr)
+	return value
+}
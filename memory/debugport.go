@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"fmt"
+	"io"
+)
+
+// Debug port message levels, sent as the first byte of a message
+const (
+	DebugLevelDebug byte = 0x01
+	DebugLevelInfo  byte = 0x02
+	DebugLevelWarn  byte = 0x03
+	DebugLevelError byte = 0x04
+)
+
+var debugLevelNames = map[byte]string{
+	DebugLevelDebug: "DEBUG",
+	DebugLevelInfo:  "INFO",
+	DebugLevelWarn:  "WARN",
+	DebugLevelError: "ERROR",
+}
+
+// debugPort collects bytes written to a spare address into log lines
+type debugPort struct {
+	addr  uint16
+	out   io.Writer
+	clock func() int // Emulated cycle count stamped on each line, if set
+	level byte
+	line  []byte
+}
+
+// SetDebugPort turns writes to addr into a virtual console for homebrew.
+// A message is an optional level byte (DebugLevelDebug..DebugLevelError)
+// followed by text, terminated by 0x00 or a newline. Writes to the port never
+// reach the underlying memory, so addr should be one the game does not use.
+// Lines are stamped with the cycle count clock returns, typically the CPU's,
+// so logs line up with traces and are the same on every run. A nil clock
+// leaves the stamp out.
+func (m *Memory) SetDebugPort(addr uint16, w io.Writer, clock func() int) {
+	m.debugPort = &debugPort{addr: addr, out: w, clock: clock, level: DebugLevelInfo}
+}
+
+func (p *debugPort) write(value byte) {
+	switch {
+	case value == 0x00 || value == '\n':
+		p.flush()
+	case len(p.line) == 0 && value >= DebugLevelDebug && value <= DebugLevelError:
+		p.level = value
+	default:
+		p.line = append(p.line, value)
+	}
+}
+
+func (p *debugPort) flush() {
+	if p.clock != nil {
+		fmt.Fprintf(p.out, "[%10d] ", p.clock())
+	}
+	fmt.Fprintf(p.out, "%-5s %s\n", debugLevelNames[p.level], p.line)
+	p.line = p.line[:0]
+	p.level = DebugLevelInfo
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDebugPort(t *testing.T) {
+	var out bytes.Buffer
+	cycles := 0
+	m := NewMemory(nil)
+	m.SetDebugPort(0xFF7F, &out, func() int { return cycles })
+
+	write := func(s string) {
+		for i := 0; i < len(s); i++ {
+			m.Write(0xFF7F, s[i])
+		}
+	}
+	cycles = 1234
+	write("hello\x00")
+	cycles = 5678
+	write("\x04oops\n")
+	write("\x01") // Level only, then an empty message at the same cycle
+	m.Write(0xFF7F, 0)
+
+	want := "[      1234] INFO  hello\n" +
+		"[      5678] ERROR oops\n" +
+		"[      5678] DEBUG \n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	if m.Peek(0xFF7F) != 0xFF {
+		t.Error("debug port write reached memory")
+	}
+}
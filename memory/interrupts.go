@@ -0,0 +1,27 @@
+package memory
+
+// Interrupt registers
+const (
+	IFAddr uint16 = 0xFF0F // Interrupt flag, set when a source requests service
+	IEAddr uint16 = 0xFFFF // Interrupt enable, one bit per source
+)
+
+// Interrupt sources as bits of IE and IF, lowest bit has highest priority
+const (
+	InterruptVBlank  byte = 0x01
+	InterruptLCDStat byte = 0x02
+	InterruptTimer   byte = 0x04
+	InterruptSerial  byte = 0x08
+	InterruptJoypad  byte = 0x10
+)
+
+// RequestInterrupt raises the given interrupt bits in IF
+func (m *Memory) RequestInterrupt(mask byte) {
+	m.io[IFAddr-IOPortsStart] |= mask & 0x1F
+}
+
+// AcknowledgeInterrupt clears the given interrupt bits in IF, as the CPU does
+// when it dispatches one
+func (m *Memory) AcknowledgeInterrupt(mask byte) {
+	m.io[IFAddr-IOPortsStart] &^= mask
+}
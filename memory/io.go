@@ -0,0 +1,130 @@
+package memory
+
+// I/O register addresses with special write behavior
+const (
+	DIVAddr  uint16 = 0xFF04 // Divider, any write resets it to zero
+	KEY1Addr uint16 = 0xFF4D // CGB speed switch, bit 7 current speed and bit 0 armed
+)
+
+// ioRegister describes how the hardware exposes one I/O address
+type ioRegister struct {
+	readMask  byte // Bits that always read back as 1 (unused or write-only)
+	writeMask byte // Bits the CPU can change, the rest are read-only
+}
+
+// unusedRegister is the behavior of addresses with nothing behind them:
+// writes are dropped and reads return 0xFF
+var unusedRegister = ioRegister{readMask: 0xFF, writeMask: 0x00}
+
+// ioRegisters maps every address in FF00-FF7F to its DMG behavior
+var ioRegisters [0x80]ioRegister
+
+func init() {
+	for i := range ioRegisters {
+		ioRegisters[i] = unusedRegister
+	}
+
+	// Read masks as documented in the Pan Docs / observed on DMG hardware
+	known := map[uint16]ioRegister{
+		0xFF00: {0xCF, 0x30}, // P1, no buttons pressed until the joypad is wired up
+		0xFF01: {0x00, 0xFF}, // SB
+		0xFF02: {0x7E, 0x81}, // SC
+		0xFF04: {0x00, 0xFF}, // DIV
+		0xFF05: {0x00, 0xFF}, // TIMA
+		0xFF06: {0x00, 0xFF}, // TMA
+		0xFF07: {0xF8, 0x07}, // TAC
+		0xFF0F: {0xE0, 0x1F}, // IF
+		0xFF10: {0x80, 0x7F}, // NR10
+		0xFF11: {0x3F, 0xFF}, // NR11, length is write-only
+		0xFF12: {0x00, 0xFF}, // NR12
+		0xFF13: {0xFF, 0xFF}, // NR13, write-only
+		0xFF14: {0xBF, 0xC7}, // NR14
+		0xFF16: {0x3F, 0xFF}, // NR21
+		0xFF17: {0x00, 0xFF}, // NR22
+		0xFF18: {0xFF, 0xFF}, // NR23, write-only
+		0xFF19: {0xBF, 0xC7}, // NR24
+		0xFF1A: {0x7F, 0x80}, // NR30
+		0xFF1B: {0xFF, 0xFF}, // NR31, write-only
+		0xFF1C: {0x9F, 0x60}, // NR32
+		0xFF1D: {0xFF, 0xFF}, // NR33, write-only
+		0xFF1E: {0xBF, 0xC7}, // NR34
+		0xFF20: {0xFF, 0x3F}, // NR41, write-only
+		0xFF21: {0x00, 0xFF}, // NR42
+		0xFF22: {0x00, 0xFF}, // NR43
+		0xFF23: {0xBF, 0xC0}, // NR44
+		0xFF24: {0x00, 0xFF}, // NR50
+		0xFF25: {0x00, 0xFF}, // NR51
+		0xFF26: {0x70, 0x80}, // NR52, channel status bits are read-only
+		0xFF40: {0x00, 0xFF}, // LCDC
+		0xFF41: {0x80, 0x78}, // STAT, mode and coincidence bits are read-only
+		0xFF42: {0x00, 0xFF}, // SCY
+		0xFF43: {0x00, 0xFF}, // SCX
+		0xFF44: {0x00, 0x00}, // LY, read-only
+		0xFF45: {0x00, 0xFF}, // LYC
+		0xFF46: {0x00, 0xFF}, // DMA
+		0xFF47: {0x00, 0xFF}, // BGP
+		0xFF48: {0x00, 0xFF}, // OBP0
+		0xFF49: {0x00, 0xFF}, // OBP1
+		0xFF4A: {0x00, 0xFF}, // WY
+		0xFF4B: {0x00, 0xFF}, // WX
+	}
+	for addr, reg := range known {
+		ioRegisters[addr-IOPortsStart] = reg
+	}
+
+	// Wave pattern RAM is plain storage
+	for addr := uint16(0xFF30); addr <= 0xFF3F; addr++ {
+		ioRegisters[addr-IOPortsStart] = ioRegister{readMask: 0x00, writeMask: 0xFF}
+	}
+}
+
+// ResetDivider clears DIV, as a write to it or entering STOP does
+func (m *Memory) ResetDivider() {
+	m.io[DIVAddr-IOPortsStart] = 0
+}
+
+// PokeIO stores an I/O register as is, bypassing write masks and side
+// effects, to set up the state the boot ROM would have left
+func (m *Memory) PokeIO(addr uint16, value byte) {
+	m.io[addr-IOPortsStart] = value
+}
+
+// PeekIO returns an I/O register as stored, without the read mask forcing
+// unused and write-only bits high
+func (m *Memory) PeekIO(addr uint16) byte {
+	return m.io[addr-IOPortsStart]
+}
+
+// SetCGBMode maps the registers only a Game Boy Color running in color mode
+// has, such as KEY1. Without it they behave like unused DMG addresses.
+func (m *Memory) SetCGBMode(on bool) {
+	m.cgb = on
+}
+
+// readIO returns an I/O register with its unused bits forced high
+func (m *Memory) readIO(addr uint16) byte {
+	if addr == KEY1Addr && m.cgb {
+		return m.io[addr-IOPortsStart] | 0x7E
+	}
+	return m.io[addr-IOPortsStart] | ioRegisters[addr-IOPortsStart].readMask
+}
+
+// writeIO stores only the writable bits of an I/O register
+func (m *Memory) writeIO(addr uint16, value byte) {
+	off := addr - IOPortsStart
+	if addr == DIVAddr {
+		m.ResetDivider() // Any write resets the divider
+		return
+	}
+	if addr == KEY1Addr && m.cgb {
+		m.io[off] = m.io[off]&0x80 | value&0x01 // The current speed only changes on STOP
+		return
+	}
+	mask := ioRegisters[off].writeMask
+	m.io[off] = m.io[off]&^mask | value&mask
+
+	// Starting a transfer with the internal clock sends SB right away
+	if addr == SCAddr && value&0x81 == 0x81 {
+		m.startSerialTransfer()
+	}
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LinkEndpoint is whatever is plugged into the other end of the link cable.
+// Exchange is called once per transfer with the byte the Game Boy shifts out
+// and returns the byte shifted in.
+type LinkEndpoint interface {
+	Exchange(out byte) byte
+}
+
+// Disconnected is an empty link port. With no partner the data line floats
+// high, so every transfer reads 0xFF.
+type Disconnected struct{}
+
+func (Disconnected) Exchange(out byte) byte {
+	return 0xFF
+}
+
+// Loopback wires the port back into itself, so every transfer reads back the
+// byte that was sent
+type Loopback struct{}
+
+func (Loopback) Exchange(out byte) byte {
+	return out
+}
+
+// ScriptedEndpoint answers transfers with a fixed sequence of bytes, for
+// driving link-cable code in tests and bots without a second instance. Once
+// the script runs out it behaves like Disconnected.
+type ScriptedEndpoint struct {
+	replies []int // Bytes to answer with, -1 for a transfer the partner lets pass
+	next    int
+}
+
+// NewScriptedEndpoint returns an endpoint that answers with replies in order
+func NewScriptedEndpoint(replies []byte) *ScriptedEndpoint {
+	s := &ScriptedEndpoint{}
+	for _, b := range replies {
+		s.replies = append(s.replies, int(b))
+	}
+	return s
+}
+
+// ParseScript reads a link script: hex bytes separated by whitespace, each
+// answering one transfer, and "wait N" to let N transfers read 0xFF before
+// the partner starts answering. Text after # on a line is ignored.
+//
+//	# Trade handshake
+//	wait 3
+//	01 60 D4
+func ParseScript(r io.Reader) (*ScriptedEndpoint, error) {
+	s := &ScriptedEndpoint{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		for i := 0; i < len(fields); i++ {
+			if fields[i] == "wait" {
+				if i+1 == len(fields) {
+					return nil, fmt.Errorf("line %d: wait needs a transfer count", line)
+				}
+				i++
+				n, err := strconv.Atoi(fields[i])
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("line %d: invalid transfer count %q", line, fields[i])
+				}
+				for ; n > 0; n-- {
+					s.replies = append(s.replies, -1)
+				}
+				continue
+			}
+			b, err := strconv.ParseUint(strings.TrimPrefix(fields[i], "$"), 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid byte %q", line, fields[i])
+			}
+			s.replies = append(s.replies, int(b))
+		}
+	}
+	return s, scanner.Err()
+}
+
+func (s *ScriptedEndpoint) Exchange(out byte) byte {
+	if s.next == len(s.replies) {
+		return 0xFF
+	}
+	reply := s.replies[s.next]
+	s.next++
+	if reply < 0 {
+		return 0xFF
+	}
+	return byte(reply)
+}
+
+// Done reports whether every scripted reply has been used
+func (s *ScriptedEndpoint) Done() bool {
+	return s.next == len(s.replies)
+}
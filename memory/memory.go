@@ -1,6 +1,9 @@
 package memory
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 const (
 	ROMStart          uint16 = 0x0000
@@ -19,16 +22,37 @@ const (
 	IOPortsEnd        uint16 = 0xFF7F
 	HRAMStart         uint16 = 0xFF80
 	HRAMEnd           uint16 = 0xFFFF
+	BootROMDisable    uint16 = 0xFF50 // Writing non-zero unmaps the boot ROM
 )
 
 // Memory structure
 type Memory struct {
-	rom  []byte       // ROM Data
-	vram [0x2000]byte // Video RAM
-	ram  [0x2000]byte // Internal RAM (0 + 1)
-	oam  [0xA0]byte   // OAM
-	io   [0x80]byte   // I/O Ports
-	hram [0x80]byte   // High RAM
+	rom     []byte       // ROM Data
+	bootROM []byte       // Boot ROM overlaid on the start of ROM, nil once unmapped
+	vram    [0x2000]byte // Video RAM
+	eram    [0x2000]byte // External RAM on the cartridge
+	wram    [0x2000]byte // Internal RAM (0 + 1)
+	oam     [0xA0]byte   // OAM
+	io      [0x80]byte   // I/O Ports
+	hram    [0x80]byte   // High RAM
+	cgb     bool         // Whether CGB-only registers are mapped
+	flat    []byte       // Plain 64KB RAM replacing the whole memory map, if set
+
+	serialOut io.Writer    // Receives bytes sent over the link cable, if set
+	link      LinkEndpoint // Other end of the link cable, if set
+	debugPort *debugPort   // Virtual console for homebrew, if set
+	tracer    Tracer       // Bus tracer, if set
+
+	observers []Observer  // Passive bus observers
+	observed  []BusAccess // Accesses not yet delivered to observers
+
+	tickers            []Ticker // CPU-clocked hardware advanced by Tick
+	normalSpeedTickers []Ticker // Hardware Tick slows down for in double speed
+	accessClock        func()   // Called on every bus access, if set
+
+	invalidAccesses int         // Accesses to addresses with nothing behind them
+	log             io.Writer   // Receives diagnostics such as invalid accesses, if set
+	onError         func(error) // Receives an InvalidAccessError per invalid access, if set
 }
 
 // NewMemory initializes the Memory structure
@@ -39,73 +63,235 @@ func NewMemory(rom []byte) Memory {
 	return m
 }
 
+// NewFlatMemory returns a bus that is 64KB of plain RAM, with no ROM, I/O
+// registers or unmapped regions. CPU test vectors such as the SM83
+// single-step tests assume this model.
+func NewFlatMemory() Memory {
+	return Memory{flat: make([]byte, BusSize)}
+}
+
+// SetBootROM overlays a boot ROM on the start of the address space until the
+// boot code writes to BootROMDisable
+func (m *Memory) SetBootROM(boot []byte) {
+	m.bootROM = boot
+}
+
+// BootROMMapped reports whether the boot ROM still shadows the cartridge
+func (m *Memory) BootROMMapped() bool {
+	return m.bootROM != nil
+}
+
 // Read retrieves the value at a given address
 func (m *Memory) Read(addr uint16) byte {
+	if m.accessClock != nil {
+		m.accessClock()
+	}
+	value, ok := m.read(addr)
+	if !ok {
+		m.invalidAccess(AccessRead, addr)
+	}
+	if m.tracer != nil {
+		m.tracer.Access(AccessRead, addr, value)
+	}
+	if len(m.observers) != 0 {
+		m.observe(AccessRead, addr, value)
+	}
+	return value
+}
+
+// read looks up an address without side effects. ok is false for addresses
+// nothing responds to, which read as 0xFF.
+func (m *Memory) read(addr uint16) (byte, bool) {
+	if m.flat != nil {
+		return m.flat[addr], true
+	}
 	switch {
 	case addr >= ROMStart && addr <= ROMEnd:
+		// The boot ROM shadows the cartridge while it is mapped
+		if addr-ROMStart < uint16(len(m.bootROM)) {
+			return m.bootROM[addr-ROMStart], true
+		}
 		// Read from ROM, checking if addr is within valid range
 		if addr-ROMStart < uint16(len(m.rom)) {
-			return m.rom[addr-ROMStart]
+			return m.rom[addr-ROMStart], true
+		}
+		if len(m.rom) == 0 {
+			return 0xFF, true // No cartridge inserted, the data bus floats high
 		}
-		fmt.Printf("Invalid memory access: ROM address %04X out of range\n", addr)
-		return 0xFF // Return a default value for invalid access
+		return 0xFF, false // Return a default value for invalid access
 	case addr >= VRAMStart && addr <= VRAMEnd:
 		// Read from Video RAM
-		return m.vram[addr-0x8000]
+		return m.vram[addr-0x8000], true
 	case addr >= ExternalRAMStart && addr <= ExternalRAMEnd:
 		// Read from External RAM (if implemented)
-		return m.ram[addr-0xA000]
+		return m.eram[addr-0xA000], true
 	case addr >= InternalRAM0Start && addr <= InternalRAM0End:
 		// Read from Internal RAM 0
-		return m.ram[addr-0xC000]
+		return m.wram[addr-0xC000], true
 	case addr >= InternalRAM1Start && addr <= InternalRAM1End:
 		// Read from Internal RAM 1
-		return m.ram[addr-0xD000]
+		return m.wram[addr-0xC000], true
 	case addr >= OAMStart && addr <= OAMEnd:
 		// Read from OAM
-		return m.oam[addr-0xFE00]
+		return m.oam[addr-0xFE00], true
 	case addr >= IOPortsStart && addr <= IOPortsEnd:
 		// Read from I/O Ports
-		return m.io[addr-0xFF00]
+		return m.readIO(addr), true
 	case addr >= HRAMStart && addr <= HRAMEnd:
 		// Read from High RAM
-		return m.hram[addr-0xFF80]
+		return m.hram[addr-0xFF80], true
 	default:
 		// Handle invalid memory access
-		fmt.Printf("Invalid memory read at address: %04X\n", addr)
-		return 0xFF // Return a default value for invalid access
+		return 0xFF, false // Return a default value for invalid access
 	}
 }
 
 // Write sets the value at a given address
 func (m *Memory) Write(addr uint16, value byte) {
+	if m.accessClock != nil {
+		m.accessClock()
+	}
+	if m.tracer != nil {
+		m.tracer.Access(AccessWrite, addr, value)
+	}
+	if len(m.observers) != 0 {
+		m.observe(AccessWrite, addr, value)
+	}
+	if m.debugPort != nil && addr == m.debugPort.addr {
+		m.debugPort.write(value) // Captured by the virtual console
+		return
+	}
+	if m.flat != nil {
+		m.flat[addr] = value
+		return
+	}
+
 	switch {
 	case addr >= ROMStart && addr <= ROMEnd:
 		// ROM should be read-only in most cases, do nothing or handle it
-		fmt.Printf("Invalid write to ROM at address: %04X\n", addr)
+		m.invalidAccess(AccessWrite, addr)
 	case addr >= VRAMStart && addr <= VRAMEnd:
 		// Write to Video RAM
 		m.vram[addr-0x8000] = value
 	case addr >= ExternalRAMStart && addr <= ExternalRAMEnd:
 		// Write to External RAM (if implemented)
-		m.ram[addr-0xA000] = value
+		m.eram[addr-0xA000] = value
 	case addr >= InternalRAM0Start && addr <= InternalRAM0End:
 		// Write to Internal RAM 0
-		m.ram[addr-0xC000] = value
+		m.wram[addr-0xC000] = value
 	case addr >= InternalRAM1Start && addr <= InternalRAM1End:
 		// Write to Internal RAM 1
-		m.ram[addr-0xD000] = value
+		m.wram[addr-0xC000] = value
 	case addr >= OAMStart && addr <= OAMEnd:
 		// Write to OAM
 		m.oam[addr-0xFE00] = value
 	case addr >= IOPortsStart && addr <= IOPortsEnd:
+		// Any non-zero write to BOOT hands the ROM area back to the cartridge
+		if addr == BootROMDisable && value != 0 {
+			m.bootROM = nil
+		}
 		// Write to I/O Ports
-		m.io[addr-0xFF00] = value
+		m.writeIO(addr, value)
 	case addr >= HRAMStart && addr <= HRAMEnd:
 		// Write to High RAM
 		m.hram[addr-0xFF80] = value
 	default:
 		// Handle invalid memory access
-		fmt.Printf("Invalid memory write at address: %04X\n", addr)
+		m.invalidAccess(AccessWrite, addr)
+	}
+}
+
+// SetLogOutput sends diagnostics to w, or discards them when w is nil. The
+// core never writes to the console itself, that is up to the frontend.
+func (m *Memory) SetLogOutput(w io.Writer) {
+	m.log = w
+}
+
+// InvalidAccessError reports a bus access that no hardware responded to,
+// such as a write to ROM or a read past the end of the cartridge
+type InvalidAccessError struct {
+	Kind AccessKind // AccessRead or AccessWrite, opcode fetches count as reads
+	Addr uint16
+}
+
+func (e *InvalidAccessError) Error() string {
+	return fmt.Sprintf("invalid memory %s at %04X", e.Kind, e.Addr)
+}
+
+// SetErrorHandler makes every invalid access get reported to fn as an
+// *InvalidAccessError, so library users can react without parsing the log.
+// A nil fn removes it.
+func (m *Memory) SetErrorHandler(fn func(error)) {
+	m.onError = fn
+}
+
+// invalidAccess records an access that no hardware responds to
+func (m *Memory) invalidAccess(kind AccessKind, addr uint16) {
+	m.invalidAccesses++
+	if m.log == nil && m.onError == nil {
+		return
+	}
+	err := &InvalidAccessError{Kind: kind, Addr: addr}
+	if m.log != nil {
+		fmt.Fprintln(m.log, err)
+	}
+	if m.onError != nil {
+		m.onError(err)
+	}
+}
+
+// InvalidAccesses returns how many accesses so far hit nothing, for
+// detecting runaway code
+func (m *Memory) InvalidAccesses() int {
+	return m.invalidAccesses
+}
+
+// BusSize is the size of the full addressable bus image
+const BusSize = 0x10000
+
+// DumpBus writes the entire 64KB address space as seen by the CPU to w.
+// Unmapped regions (echo RAM, the unusable area) read back as 0xFF. It reads
+// like Peek, so dumping is not traced, clocked or counted as bus activity.
+func (m *Memory) DumpBus(w io.Writer) error {
+	image := make([]byte, BusSize)
+	if m.flat != nil {
+		copy(image, m.flat)
+		_, err := w.Write(image)
+		return err
+	}
+	for addr := 0; addr < BusSize; addr++ {
+		switch {
+		case addr <= int(ROMEnd) && addr >= len(m.rom) && addr >= len(m.bootROM):
+			image[addr] = 0xFF // Past the end of the loaded ROM
+		case addr > int(InternalRAM1End) && addr < int(OAMStart), addr > int(OAMEnd) && addr < int(IOPortsStart):
+			image[addr] = 0xFF // Echo RAM and unusable area
+		default:
+			image[addr], _ = m.read(uint16(addr))
+		}
+	}
+	_, err := w.Write(image)
+	return err
+}
+
+// LoadBus builds a scratch machine from a 64KB bus image. The first 32KB
+// become the ROM and the RAM regions and I/O registers are restored directly,
+// like a save state, so no register write side effects such as a serial
+// transfer or a divider reset happen. Bits an I/O register reads as 1
+// regardless of its contents are not restored.
+func LoadBus(r io.Reader) (Memory, error) {
+	image := make([]byte, BusSize)
+	if _, err := io.ReadFull(r, image); err != nil {
+		return Memory{}, fmt.Errorf("bus image must be %d bytes: %w", BusSize, err)
+	}
+
+	m := NewMemory(image[:ROMEnd+1])
+	copy(m.vram[:], image[VRAMStart:])
+	copy(m.eram[:], image[ExternalRAMStart:])
+	copy(m.wram[:], image[InternalRAM0Start:])
+	copy(m.oam[:], image[OAMStart:])
+	for i := range m.io {
+		m.io[i] = image[int(IOPortsStart)+i] &^ ioRegisters[i].readMask
 	}
+	copy(m.hram[:], image[HRAMStart:])
+	return m, nil
 }
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRAMRegionsDoNotAlias(t *testing.T) {
+	m := NewMemory(make([]byte, 0x8000))
+	m.Write(0xA123, 0x11)
+	m.Write(0xC123, 0x22)
+	m.Write(0xD123, 0x33)
+	for addr, want := range map[uint16]byte{0xA123: 0x11, 0xC123: 0x22, 0xD123: 0x33} {
+		if got := m.Read(addr); got != want {
+			t.Errorf("[%04X] = %02X, want %02X", addr, got, want)
+		}
+	}
+}
+
+// countingTracer counts bus accesses
+type countingTracer int
+
+func (n *countingTracer) Access(AccessKind, uint16, byte) { *n++ }
+
+func TestDumpBusHasNoSideEffects(t *testing.T) {
+	m := NewMemory(make([]byte, 0x4000)) // Shorter than the ROM area
+	var accesses countingTracer
+	m.SetTracer(&accesses)
+	clock := 0
+	m.SetAccessClock(func() { clock++ })
+
+	var image bytes.Buffer
+	if err := m.DumpBus(&image); err != nil {
+		t.Fatal(err)
+	}
+	if accesses != 0 || clock != 0 || m.InvalidAccesses() != 0 {
+		t.Errorf("dump made %d traced accesses, %d clocked and %d invalid, want none", accesses, clock, m.InvalidAccesses())
+	}
+	if got := image.Bytes()[0x4000]; got != 0xFF {
+		t.Errorf("past the end of ROM dumped as %02X, want FF", got)
+	}
+}
+
+func TestDumpBusFlat(t *testing.T) {
+	m := NewFlatMemory()
+	m.Write(0x0000, 0x12)
+	m.Write(0x7FFF, 0x34)
+	m.Write(0xE000, 0x56) // Echo RAM is plain memory on a flat bus
+	var image bytes.Buffer
+	if err := m.DumpBus(&image); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[int]byte{0x0000: 0x12, 0x7FFF: 0x34, 0xE000: 0x56} {
+		if got := image.Bytes()[addr]; got != want {
+			t.Errorf("[%04X] dumped as %02X, want %02X", addr, got, want)
+		}
+	}
+}
+
+func TestLoadBusRoundTrip(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0100] = 0xC3
+	m := NewMemory(rom)
+	m.Write(0x8000, 0x01)
+	m.Write(0xA000, 0x02)
+	m.Write(0xC000, 0x03)
+	m.Write(0xDFFF, 0x04)
+	m.Write(0xFE00, 0x05)
+	m.Write(0xFF80, 0x06)
+	m.Write(IEAddr, 0x1F)
+	m.Write(0xFF01, 'A') // SB
+	m.Write(0xFF40, 0x91)
+	m.PokeIO(DIVAddr, 0xAB)
+	m.PokeIO(SCAddr, 0x81) // Transfer in progress
+
+	var image bytes.Buffer
+	if err := m.DumpBus(&image); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBus(bytes.NewReader(image.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serial bytes.Buffer
+	loaded.SetSerialOutput(&serial)
+
+	var again bytes.Buffer
+	if err := loaded.DumpBus(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(image.Bytes(), again.Bytes()) {
+		for addr := range image.Bytes() {
+			if image.Bytes()[addr] != again.Bytes()[addr] {
+				t.Fatalf("[%04X] = %02X after a round trip, want %02X", addr, again.Bytes()[addr], image.Bytes()[addr])
+			}
+		}
+	}
+	if loaded.Peek(DIVAddr) != 0xAB {
+		t.Errorf("DIV = %02X, want AB", loaded.Peek(DIVAddr))
+	}
+	if serial.Len() != 0 {
+		t.Errorf("loading started a serial transfer: %q", serial.String())
+	}
+}
+
+func TestLoadBusShortImage(t *testing.T) {
+	if _, err := LoadBus(bytes.NewReader(make([]byte, 100))); err == nil {
+		t.Error("short image accepted")
+	}
+}
+
+func TestPeekIsNotAnAccess(t *testing.T) {
+	m := NewMemory(make([]byte, 0x4000))
+	var accesses countingTracer
+	m.SetTracer(&accesses)
+	if got := m.Peek(0x4000); got != 0xFF {
+		t.Errorf("peek past the end of ROM = %02X, want FF", got)
+	}
+	m.Peek(0xE000) // Echo RAM
+	if accesses != 0 || m.InvalidAccesses() != 0 {
+		t.Errorf("peeks made %d traced and %d invalid accesses, want none", accesses, m.InvalidAccesses())
+	}
+	m.Read(0x4000)
+	m.Fetch(0xE000)
+	if accesses != 2 || m.InvalidAccesses() != 2 {
+		t.Errorf("reads made %d traced and %d invalid accesses, want 2 and 2", accesses, m.InvalidAccesses())
+	}
+}
@@ -0,0 +1,58 @@
+package memory
+
+// observeBatch is how many accesses are buffered before observers see them
+const observeBatch = 1024
+
+// BusAccess is one access seen on the bus
+type BusAccess struct {
+	Kind  AccessKind
+	Addr  uint16
+	Value byte
+}
+
+// Observer watches bus traffic without being able to change it. Accesses are
+// delivered in batches, oldest first. The slice is reused after Observe
+// returns, so observers must copy anything they keep.
+type Observer interface {
+	Observe(accesses []BusAccess)
+}
+
+// AddObserver attaches o alongside any tracer and other observers. With no
+// observers attached, recording costs one length check per access.
+func (m *Memory) AddObserver(o Observer) {
+	if m.observed == nil {
+		m.observed = make([]BusAccess, 0, observeBatch)
+	}
+	m.observers = append(m.observers, o)
+}
+
+// RemoveObserver flushes pending accesses and detaches o
+func (m *Memory) RemoveObserver(o Observer) {
+	m.FlushObservers()
+	for i, attached := range m.observers {
+		if attached == o {
+			m.observers = append(m.observers[:i], m.observers[i+1:]...)
+			break
+		}
+	}
+}
+
+// FlushObservers delivers the accesses buffered so far, for example at the
+// end of a frame or before reading an observer's results
+func (m *Memory) FlushObservers() {
+	if len(m.observed) == 0 {
+		return
+	}
+	for _, o := range m.observers {
+		o.Observe(m.observed)
+	}
+	m.observed = m.observed[:0]
+}
+
+// observe buffers an access for the attached observers
+func (m *Memory) observe(kind AccessKind, addr uint16, value byte) {
+	m.observed = append(m.observed, BusAccess{kind, addr, value})
+	if len(m.observed) == observeBatch {
+		m.FlushObservers()
+	}
+}
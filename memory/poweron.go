@@ -0,0 +1,15 @@
+package memory
+
+import "math/rand"
+
+// RandomizeRAM fills video RAM, work RAM, OAM and high RAM with bytes from
+// rng, standing in for the garbage real hardware powers on with. Seeding rng
+// the same way reproduces a run exactly, while varying the seed shakes out
+// code that reads RAM before initializing it. Without this, RAM starts
+// zeroed.
+func (m *Memory) RandomizeRAM(rng *rand.Rand) {
+	rng.Read(m.vram[:])
+	rng.Read(m.wram[:])
+	rng.Read(m.oam[:])
+	rng.Read(m.hram[:len(m.hram)-1]) // IE at FFFF powers on cleared
+}
@@ -0,0 +1,37 @@
+package memory
+
+import "io"
+
+// Serial port registers
+const (
+	SBAddr uint16 = 0xFF01 // Serial transfer data
+	SCAddr uint16 = 0xFF02 // Serial transfer control
+)
+
+// SetSerialOutput makes every byte the game sends over the link cable get
+// written to w. This is the usual printf channel for test ROMs and homebrew.
+func (m *Memory) SetSerialOutput(w io.Writer) {
+	m.serialOut = w
+}
+
+// SetLinkEndpoint plugs e into the link port. Without one the port behaves
+// as Disconnected.
+func (m *Memory) SetLinkEndpoint(e LinkEndpoint) {
+	m.link = e
+}
+
+// startSerialTransfer completes an internally clocked transfer right away,
+// shifting in whatever the link endpoint answers
+func (m *Memory) startSerialTransfer() {
+	sb := m.io[SBAddr-IOPortsStart]
+	if m.serialOut != nil {
+		m.serialOut.Write([]byte{sb})
+	}
+	in := byte(0xFF)
+	if m.link != nil {
+		in = m.link.Exchange(sb)
+	}
+	m.io[SBAddr-IOPortsStart] = in
+	m.io[SCAddr-IOPortsStart] &^= 0x80 // Transfer finished
+	m.RequestInterrupt(InterruptSerial)
+}
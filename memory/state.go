@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Version of the memory save state layout
+func (m *Memory) Version() uint16 {
+	return 2 // Version 2 split external RAM from work RAM
+}
+
+// Save writes all writable memory regions to w. The cartridge and boot ROM
+// contents are not part of the state, only whether the boot ROM is mapped.
+func (m *Memory) Save(w io.Writer) error {
+	fields := []any{
+		m.bootROM != nil,
+		m.vram[:], m.eram[:], m.wram[:], m.oam[:], m.io[:], m.hram[:],
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load restores the regions written by Save
+func (m *Memory) Load(r io.Reader) error {
+	var bootMapped bool
+	if err := binary.Read(r, binary.LittleEndian, &bootMapped); err != nil {
+		return err
+	}
+	for _, region := range [][]byte{m.vram[:], m.eram[:], m.wram[:], m.oam[:], m.io[:], m.hram[:]} {
+		if _, err := io.ReadFull(r, region); err != nil {
+			return err
+		}
+	}
+	if !bootMapped {
+		m.bootROM = nil // The boot ROM had already handed over control
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package memory
+
+// AccessKind tells a bus tracer what kind of access happened
+type AccessKind int
+
+const (
+	AccessRead  AccessKind = iota // Data read
+	AccessWrite                   // Data write
+	AccessFetch                   // Opcode fetch
+)
+
+func (k AccessKind) String() string {
+	switch k {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	default:
+		return "fetch"
+	}
+}
+
+// Tracer receives every bus access while attached
+type Tracer interface {
+	Access(kind AccessKind, addr uint16, value byte)
+}
+
+// SetTracer attaches a bus tracer, or detaches it when t is nil
+func (m *Memory) SetTracer(t Tracer) {
+	m.tracer = t
+}
+
+// Fetch reads an opcode byte, reported to the tracer as an opcode fetch
+func (m *Memory) Fetch(addr uint16) byte {
+	if m.accessClock != nil {
+		m.accessClock()
+	}
+	value, ok := m.read(addr)
+	if !ok {
+		m.invalidAccess(AccessRead, addr) // Opcode fetches count as reads
+	}
+	if m.tracer != nil {
+		m.tracer.Access(AccessFetch, addr, value)
+	}
+	if len(m.observers) != 0 {
+		m.observe(AccessFetch, addr, value)
+	}
+	return value
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	cpuPkg "clockworkgnome/cpu"
+)
+
+// printOpcodes implements the "opcodes" command, dumping the instruction
+// metadata the core is built from
+func printOpcodes(args []string) {
+	fs := flag.NewFlagSet("opcodes", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the tables as JSON")
+	fs.Parse(args)
+
+	base, cb := cpuPkg.Opcodes(), cpuPkg.CBOpcodes()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string][]cpuPkg.OpcodeEntry{"base": base, "cb": cb}); err != nil {
+			fmt.Printf("Failed to encode opcodes: %v\n", err)
+		}
+		return
+	}
+
+	for _, op := range append(base, cb...) {
+		cycles := fmt.Sprint(op.Cycles)
+		if op.CyclesNotTaken != 0 {
+			cycles = fmt.Sprintf("%d/%d", op.Cycles, op.CyclesNotTaken)
+		}
+		line := fmt.Sprintf("%-4s  %-16s  %d  %-5s  %s", op.Opcode, op.Mnemonic, op.Length, cycles, op.Flags)
+		if !op.Implemented {
+			line += "  unimplemented"
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// poke is a run of bytes stored at an address before emulation starts
+type poke struct {
+	addr uint16
+	data []byte
+}
+
+// pokeList collects repeated -poke ADDR=BYTES flags, e.g. C000=3E42EA00D076
+type pokeList []poke
+
+func (p *pokeList) String() string {
+	parts := make([]string, len(*p))
+	for i, pk := range *p {
+		parts[i] = fmt.Sprintf("%04X=%X", pk.addr, pk.data)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p *pokeList) Set(s string) error {
+	addrText, dataText, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected ADDR=BYTES, e.g. C000=3E42")
+	}
+	addr, err := strconv.ParseUint(strings.TrimPrefix(addrText, "$"), 16, 16)
+	if err != nil {
+		return fmt.Errorf("bad address %q", addrText)
+	}
+	data, err := hex.DecodeString(dataText)
+	if err != nil || len(data) == 0 {
+		return fmt.Errorf("bad bytes %q, expected hex pairs", dataText)
+	}
+	if int(addr)+len(data) > 0x10000 {
+		return fmt.Errorf("%d bytes at %04X run past FFFF", len(data), addr)
+	}
+	*p = append(*p, poke{addr: uint16(addr), data: data})
+	return nil
+}
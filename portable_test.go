@@ -0,0 +1,46 @@
+package main
+
+import (
+	"go/build"
+	"testing"
+)
+
+// corePackages must build anywhere Go runs, including js/wasm and mobile
+var corePackages = []string{"alu", "cpu", "memory", "state", "trace", "headless", "debugger"}
+
+// osImports reach the operating system or need cgo, which only frontends
+// such as main.go may use
+var osImports = map[string]bool{
+	"C": true, "unsafe": true, "syscall": true, "plugin": true,
+	"os": true, "os/exec": true, "os/signal": true, "os/user": true,
+	"io/ioutil": true, "net": true, "log": true,
+}
+
+// TestCorePortable checks the core packages import nothing OS specific on
+// any target, so a file with its own build constraint cannot sneak one in
+// for a single platform
+func TestCorePortable(t *testing.T) {
+	targets := [][2]string{
+		{"linux", "amd64"}, {"js", "wasm"}, {"wasip1", "wasm"},
+		{"plan9", "amd64"}, {"android", "arm64"}, {"ios", "arm64"},
+	}
+	for _, target := range targets {
+		ctx := build.Default
+		ctx.GOOS, ctx.GOARCH = target[0], target[1]
+		ctx.CgoEnabled = true // So cgo files show up as importing "C"
+		for _, tags := range [][]string{nil, {"checked"}} {
+			ctx.BuildTags = tags
+			for _, dir := range corePackages {
+				pkg, err := ctx.ImportDir(dir, 0)
+				if err != nil {
+					t.Fatalf("%s/%s %s: %v", target[0], target[1], dir, err)
+				}
+				for _, path := range pkg.Imports {
+					if osImports[path] {
+						t.Errorf("%s/%s: %s imports %q", target[0], target[1], dir, path)
+					}
+				}
+			}
+		}
+	}
+}
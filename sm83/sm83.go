@@ -0,0 +1,170 @@
+// Package sm83 runs the community SM83 single-step test vectors against the
+// CPU. Each vector gives an initial machine state, the state after executing
+// one instruction and the bus activity of every M-cycle in between, so a
+// failure points at a single opcode.
+package sm83
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"clockworkgnome/cpu"
+	"clockworkgnome/memory"
+)
+
+// State is a register and memory snapshot as the vectors encode it
+type State struct {
+	PC  uint16      `json:"pc"`
+	SP  uint16      `json:"sp"`
+	A   byte        `json:"a"`
+	B   byte        `json:"b"`
+	C   byte        `json:"c"`
+	D   byte        `json:"d"`
+	E   byte        `json:"e"`
+	F   byte        `json:"f"`
+	H   byte        `json:"h"`
+	L   byte        `json:"l"`
+	IME byte        `json:"ime"`
+	IE  byte        `json:"ie"`
+	RAM [][2]uint16 `json:"ram"` // Address and value pairs
+}
+
+// Cycle is the bus activity of one M-cycle. Idle cycles are nil in Test.Cycles.
+type Cycle struct {
+	Addr  uint16
+	Value byte
+	Pins  string // Read, write and memory request pins, e.g. "r-m" or "-wm"
+}
+
+// UnmarshalJSON decodes the [addr, value, pins] triple of the vectors
+func (c *Cycle) UnmarshalJSON(data []byte) error {
+	var raw [3]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	addr, ok1 := raw[0].(float64)
+	value, ok2 := raw[1].(float64)
+	pins, ok3 := raw[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("sm83: malformed cycle %s", data)
+	}
+	c.Addr, c.Value, c.Pins = uint16(addr), byte(value), pins
+	return nil
+}
+
+// Test is one vector
+type Test struct {
+	Name    string   `json:"name"`
+	Initial State    `json:"initial"`
+	Final   State    `json:"final"`
+	Cycles  []*Cycle `json:"cycles"`
+}
+
+// Load decodes a vector file, one JSON array of tests per opcode
+func Load(r io.Reader) ([]Test, error) {
+	var tests []Test
+	if err := json.NewDecoder(r).Decode(&tests); err != nil {
+		return nil, fmt.Errorf("sm83: %w", err)
+	}
+	return tests, nil
+}
+
+// busRecorder collects the accesses of one instruction
+type busRecorder struct {
+	accesses []memory.BusAccess
+}
+
+func (b *busRecorder) Access(kind memory.AccessKind, addr uint16, value byte) {
+	b.accesses = append(b.accesses, memory.BusAccess{Kind: kind, Addr: addr, Value: value})
+}
+
+// Run executes a test on a fresh CPU with a flat 64KB bus and returns every
+// difference from the expected outcome, or nil if it passed.
+//
+// The vectors model the SM83's fetch overlap: the opcode at PC-1 has already
+// been fetched when a test starts, and the instruction's last M-cycle fetches
+// the next opcode, leaving PC one past it. Run starts the CPU on the opcode
+// and translates its fetch into that prefetch. Idle cycles do not touch the
+// bus here, so the cycle count and the order of the accesses are compared.
+func Run(t Test) []string {
+	mem := memory.NewFlatMemory()
+	mem.Write(memory.IEAddr, t.Initial.IE)
+	for _, cell := range t.Initial.RAM {
+		mem.Write(cell[0], byte(cell[1]))
+	}
+	c := cpu.NewCPU(cpu.DMG)
+	c.A, c.F = t.Initial.A, t.Initial.F
+	c.B, c.C = t.Initial.B, t.Initial.C
+	c.D, c.E = t.Initial.D, t.Initial.E
+	c.H, c.L = t.Initial.H, t.Initial.L
+	c.SP, c.PC = t.Initial.SP, t.Initial.PC-1
+	c.IME = t.Initial.IME != 0
+
+	bus := &busRecorder{}
+	mem.SetTracer(bus)
+	c.Execute(&mem)
+	mem.SetTracer(nil)
+
+	// Move the opcode fetch to the end, where the vectors prefetch
+	accesses := bus.accesses
+	if len(accesses) > 0 {
+		accesses = accesses[1:]
+	}
+	accesses = append(accesses, memory.BusAccess{Kind: memory.AccessFetch, Addr: c.PC, Value: mem.Peek(c.PC)})
+	pc := c.PC + 1
+
+	var diffs []string
+	check := func(name string, got, want int) {
+		if got != want {
+			diffs = append(diffs, fmt.Sprintf("%s: got %X, want %X", name, got, want))
+		}
+	}
+	want := t.Final
+	check("A", int(c.A), int(want.A))
+	check("F", int(c.F), int(want.F))
+	check("B", int(c.B), int(want.B))
+	check("C", int(c.C), int(want.C))
+	check("D", int(c.D), int(want.D))
+	check("E", int(c.E), int(want.E))
+	check("H", int(c.H), int(want.H))
+	check("L", int(c.L), int(want.L))
+	check("SP", int(c.SP), int(want.SP))
+	check("PC", int(pc), int(want.PC))
+	check("IME", int(btoi(c.IME)), int(want.IME))
+	for _, cell := range want.RAM {
+		check(fmt.Sprintf("[%04X]", cell[0]), int(mem.Peek(cell[0])), int(cell[1]))
+	}
+	check("cycles", c.Cycles, 4*len(t.Cycles))
+
+	var expected []Cycle
+	for _, cycle := range t.Cycles {
+		if cycle != nil {
+			expected = append(expected, *cycle)
+		}
+	}
+	if len(accesses) != len(expected) {
+		diffs = append(diffs, fmt.Sprintf("bus: %d accesses, want %d", len(accesses), len(expected)))
+		return diffs
+	}
+	for i, access := range accesses {
+		kind := "r"
+		if access.Kind == memory.AccessWrite {
+			kind = "w"
+		}
+		cycle := expected[i]
+		if access.Addr != cycle.Addr || access.Value != cycle.Value || !strings.Contains(cycle.Pins, kind) {
+			diffs = append(diffs, fmt.Sprintf("bus access %d: %s %04X=%02X, want %s %04X=%02X",
+				i, kind, access.Addr, access.Value, cycle.Pins, cycle.Addr, cycle.Value))
+		}
+	}
+	return diffs
+}
+
+func btoi(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
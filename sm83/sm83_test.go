@@ -0,0 +1,76 @@
+package sm83
+
+import (
+	"strings"
+	"testing"
+)
+
+// vectors are hand-written in the upstream format: PC starts one past the
+// prefetched opcode and the last M-cycle fetches the next one
+const vectors = `[
+{"name": "00 NOP",
+ "initial": {"pc": 257, "sp": 0, "a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 0], [257, 0]]},
+ "final": {"pc": 258, "sp": 0, "a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 0], [257, 0]]},
+ "cycles": [[257, 0, "r-m"]]},
+{"name": "77 LD (HL),A",
+ "initial": {"pc": 257, "sp": 0, "a": 66, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 192, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 119], [257, 0], [49152, 0]]},
+ "final": {"pc": 258, "sp": 0, "a": 66, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 192, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 119], [257, 0], [49152, 66]]},
+ "cycles": [[49152, 66, "-wm"], [257, 0, "r-m"]]},
+{"name": "C5 PUSH BC",
+ "initial": {"pc": 257, "sp": 53248, "a": 0, "b": 18, "c": 52, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 197], [257, 0]]},
+ "final": {"pc": 258, "sp": 53246, "a": 0, "b": 18, "c": 52, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[53247, 18], [53246, 52]]},
+ "cycles": [null, [53247, 18, "-wm"], [53246, 52, "-wm"], [257, 0, "r-m"]]},
+{"name": "20 JR NZ,e taken",
+ "initial": {"pc": 257, "sp": 0, "a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 32], [257, 5], [263, 0]]},
+ "final": {"pc": 264, "sp": 0, "a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 32], [257, 5], [263, 0]]},
+ "cycles": [[257, 5, "r-m"], null, [263, 0, "r-m"]]}
+]`
+
+// wrong expects LD (HL),A to store the wrong value and take an extra cycle
+const wrong = `[
+{"name": "77 LD (HL),A wrong",
+ "initial": {"pc": 257, "sp": 0, "a": 66, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 192, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[256, 119], [257, 0], [49152, 0]]},
+ "final": {"pc": 258, "sp": 0, "a": 66, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 192, "l": 0, "ime": 0, "ie": 0,
+  "ram": [[49152, 67]]},
+ "cycles": [null, [49152, 67, "-wm"], [257, 0, "r-m"]]}
+]`
+
+func TestRunPasses(t *testing.T) {
+	tests, err := Load(strings.NewReader(vectors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if diffs := Run(tt); diffs != nil {
+			t.Errorf("%s:\n%s", tt.Name, strings.Join(diffs, "\n"))
+		}
+	}
+}
+
+func TestRunReportsDiffs(t *testing.T) {
+	tests, err := Load(strings.NewReader(wrong))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs := strings.Join(Run(tests[0]), "\n")
+	for _, want := range []string{"[C000]: got 42, want 43", "cycles: got 8, want C", "bus access 0"} {
+		if !strings.Contains(diffs, want) {
+			t.Errorf("diffs missing %q:\n%s", want, diffs)
+		}
+	}
+}
+
+func TestLoadMalformedCycle(t *testing.T) {
+	if _, err := Load(strings.NewReader(`[{"cycles": [[1, 2]]}]`)); err == nil {
+		t.Error("expected an error for a cycle without pins")
+	}
+}
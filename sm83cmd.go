@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"clockworkgnome/sm83"
+)
+
+// runSM83 implements the "sm83" command, running single-step test vector
+// files (one per opcode, e.g. v1/*.json) against the CPU
+func runSM83(args []string) {
+	fs := flag.NewFlagSet("sm83", flag.ExitOnError)
+	verbose := fs.Int("v", 1, "print the differences of up to this many failing tests per file")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: sm83 [-v N] FILE.json...")
+		return
+	}
+	passedFiles := 0
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open test vectors: %v\n", err)
+			return
+		}
+		tests, err := sm83.Load(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			return
+		}
+
+		failed := 0
+		for _, t := range tests {
+			diffs := sm83.Run(t)
+			if diffs == nil {
+				continue
+			}
+			if failed < *verbose {
+				fmt.Printf("%s %q:\n", filepath.Base(path), t.Name)
+				for _, d := range diffs {
+					fmt.Printf("  %s\n", d)
+				}
+			}
+			failed++
+		}
+		if failed == 0 {
+			passedFiles++
+		}
+		fmt.Printf("%s: %d/%d passed\n", filepath.Base(path), len(tests)-failed, len(tests))
+	}
+	fmt.Printf("%d/%d files passed\n", passedFiles, fs.NArg())
+}
@@ -0,0 +1,88 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Serializer is implemented by every subsystem that takes part in save
+// states, rewind and netplay sync
+type Serializer interface {
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+	Version() uint16
+}
+
+// Section pairs a subsystem with the name it is stored under
+type Section struct {
+	Name string
+	S    Serializer
+}
+
+// Save writes each section as name, version, payload length and payload so
+// subsystems can be versioned independently
+func Save(w io.Writer, sections ...Section) error {
+	for _, sec := range sections {
+		var payload bytes.Buffer
+		if err := sec.S.Save(&payload); err != nil {
+			return fmt.Errorf("state: saving %s: %w", sec.Name, err)
+		}
+		header := []any{
+			uint8(len(sec.Name)), []byte(sec.Name),
+			sec.S.Version(),
+			uint32(payload.Len()),
+		}
+		for _, field := range header {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(payload.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load restores sections in the same order they were saved, rejecting
+// payloads written by a different version of a subsystem
+func Load(r io.Reader, sections ...Section) error {
+	for _, sec := range sections {
+		var nameLen uint8
+		if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
+			return fmt.Errorf("state: reading %s header: %w", sec.Name, err)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return fmt.Errorf("state: reading %s header: %w", sec.Name, err)
+		}
+		if string(name) != sec.Name {
+			return fmt.Errorf("state: expected section %s, found %s", sec.Name, name)
+		}
+
+		var (
+			version uint16
+			length  uint32
+		)
+		if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+			return fmt.Errorf("state: reading %s header: %w", sec.Name, err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return fmt.Errorf("state: reading %s header: %w", sec.Name, err)
+		}
+		if version != sec.S.Version() {
+			return fmt.Errorf("state: %s is version %d, expected %d", sec.Name, version, sec.S.Version())
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return fmt.Errorf("state: reading %s: %w", sec.Name, err)
+		}
+		if err := sec.S.Load(bytes.NewReader(payload)); err != nil {
+			return fmt.Errorf("state: loading %s: %w", sec.Name, err)
+		}
+	}
+	return nil
+}
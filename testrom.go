@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"clockworkgnome/testrom"
+)
+
+// runTestROM implements the "testrom" command: it generates a cartridge
+// exercising one opcode, optionally saves it for a flashcart, and checks the
+// core against the reference results
+func runTestROM(args []string) {
+	fs := flag.NewFlagSet("testrom", flag.ExitOnError)
+	outPath := fs.String("o", "", "also write the generated cartridge to this file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: testrom [-o FILE] OPCODE (hex, e.g. 8E)")
+		return
+	}
+	opcode, err := strconv.ParseUint(fs.Arg(0), 16, 8)
+	if err != nil {
+		fmt.Printf("Invalid opcode: %s\n", fs.Arg(0))
+		return
+	}
+	rom, err := testrom.Generate(byte(opcode))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *outPath != "" {
+		if err := os.WriteFile(*outPath, rom.Image, 0o644); err != nil {
+			fmt.Printf("Failed to write test ROM: %v\n", err)
+			return
+		}
+	}
+
+	mismatches, err := testrom.Check(rom)
+	if err != nil {
+		fmt.Printf("Test ROM did not finish: %v\n", err)
+		return
+	}
+	for _, m := range mismatches {
+		fmt.Println(m)
+	}
+	fmt.Printf("Opcode %02X: %d of %d cases match\n", rom.Opcode, len(rom.Cases)-len(mismatches), len(rom.Cases))
+}
@@ -0,0 +1,95 @@
+package testrom
+
+// The reference ALU below is deliberately written apart from the alu
+// package the core uses: it works on wide integers and takes the half carry
+// from the carry into bit 4 (a^b^result), the way the flag is usually
+// documented. A bug shared by the core and its reference would otherwise
+// pass unnoticed.
+
+const (
+	flagZ byte = 0x80
+	flagN byte = 0x40
+	flagH byte = 0x20
+	flagC byte = 0x10
+)
+
+func refFlags(result int, n, h, c bool) byte {
+	var f byte
+	if result&0xFF == 0 {
+		f |= flagZ
+	}
+	if n {
+		f |= flagN
+	}
+	if h {
+		f |= flagH
+	}
+	if c {
+		f |= flagC
+	}
+	return f
+}
+
+func carryIn(f byte) int {
+	if f&flagC != 0 {
+		return 1
+	}
+	return 0
+}
+
+func refAdd(a, b, carry int) (byte, byte) {
+	r := a + b + carry
+	return byte(r), refFlags(r, false, (a^b^r)&0x10 != 0, r > 0xFF)
+}
+
+func refSub(a, b, carry int) (byte, byte) {
+	r := a - b - carry
+	return byte(r), refFlags(r, true, (a^b^r)&0x10 != 0, r < 0)
+}
+
+// refALU gives the results of ADD, ADC, SUB, SBC, AND, XOR, OR and CP, in
+// opcode order
+var refALU = [8]func(a, b, f byte) (byte, byte){
+	func(a, b, f byte) (byte, byte) { return refAdd(int(a), int(b), 0) },
+	func(a, b, f byte) (byte, byte) { return refAdd(int(a), int(b), carryIn(f)) },
+	func(a, b, f byte) (byte, byte) { return refSub(int(a), int(b), 0) },
+	func(a, b, f byte) (byte, byte) { return refSub(int(a), int(b), carryIn(f)) },
+	func(a, b, f byte) (byte, byte) { return a & b, refFlags(int(a&b), false, true, false) },
+	func(a, b, f byte) (byte, byte) { return a ^ b, refFlags(int(a^b), false, false, false) },
+	func(a, b, f byte) (byte, byte) { return a | b, refFlags(int(a|b), false, false, false) },
+	func(a, b, f byte) (byte, byte) { _, f = refSub(int(a), int(b), 0); return a, f },
+}
+
+// refInc and refDec leave the carry flag as it was
+func refInc(v, f byte) (byte, byte) {
+	r := int(v) + 1
+	return byte(r), refFlags(r, false, (int(v)^1^r)&0x10 != 0, false) | f&flagC
+}
+
+func refDec(v, f byte) (byte, byte) {
+	r := int(v) - 1
+	return byte(r), refFlags(r, true, (int(v)^1^r)&0x10 != 0, false) | f&flagC
+}
+
+// refDaa adjusts the high digit first, judged on the unadjusted value, then
+// the low digit
+func refDaa(v, f byte) (byte, byte) {
+	a, n, h, c := int(v), f&flagN != 0, f&flagH != 0, f&flagC != 0
+	if !n {
+		if c || a > 0x99 {
+			a += 0x60
+			c = true
+		}
+		if h || a&0x0F > 0x09 {
+			a += 0x06
+		}
+	} else {
+		if c {
+			a -= 0x60
+		}
+		if h {
+			a -= 0x06
+		}
+	}
+	return byte(a), refFlags(a, n, false, c)
+}
@@ -0,0 +1,212 @@
+// Package testrom generates tiny cartridges that run one 8-bit arithmetic or
+// logic opcode over a spread of operands and flag inputs, storing every
+// result in WRAM. The same image can be checked against the core here and
+// run on real hardware from a flashcart, so the two WRAM dumps can be
+// compared byte for byte.
+package testrom
+
+import (
+	"fmt"
+
+	"clockworkgnome/headless"
+)
+
+const (
+	codeStart   uint16 = 0x0150 // First instruction after the header
+	resultStart uint16 = 0xC000 // Each case stores A then F from here on
+)
+
+// values are the operands and accumulator inputs tried for every opcode,
+// picked around the nibble and sign boundaries where flags change
+var values = []byte{0x00, 0x01, 0x0F, 0x10, 0x7F, 0x80, 0x99, 0x9A, 0xFF}
+
+// nintendoLogo must sit at 0x104 for the boot ROM to start the cartridge
+var nintendoLogo = [48]byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83,
+	0x00, 0x0C, 0x00, 0x0D, 0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E,
+	0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99, 0xBB, 0xBB, 0x67, 0x63,
+	0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// Case is one execution of the opcode under test
+type Case struct {
+	A, Operand, F byte // Inputs. Operand is unused by opcodes without one.
+	WantA, WantF  byte // Destination register and F the reference ALU expects
+	Addr          uint16
+}
+
+// ROM is a generated test cartridge
+type ROM struct {
+	Opcode byte
+	Image  []byte
+	Cases  []Case
+	End    uint16 // Address of the final JR $ loop
+}
+
+// Mismatch is a case where the core disagreed with the reference
+type Mismatch struct {
+	Case
+	GotA, GotF byte
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("A=%02X operand=%02X F=%02X: got %02X/%02X, want %02X/%02X",
+		m.A, m.Operand, m.F, m.GotA, m.GotF, m.WantA, m.WantF)
+}
+
+// test describes how to set up and check an opcode
+type test struct {
+	operand int // Register code (0-7) holding the operand, 8 for an immediate, -1 for none
+	dest    int // Register code the result lands in
+	ref     func(a, operand, f byte) (byte, byte)
+}
+
+// lookup returns how to test opcode, if it is one the generator supports:
+// ALU ops on a register or immediate, INC r, DEC r and DAA
+func lookup(opcode byte) (test, bool) {
+	reg := int(opcode & 0x07)
+	switch {
+	case opcode >= 0x80 && opcode <= 0xBF && reg != 6:
+		return test{operand: reg, dest: 7, ref: refALU[opcode>>3&0x07]}, true
+	case opcode&0xC7 == 0xC6:
+		return test{operand: 8, dest: 7, ref: refALU[opcode>>3&0x07]}, true
+	case opcode < 0x40 && opcode&0x07 == 0x04 && opcode>>3 != 6:
+		return test{operand: -1, dest: int(opcode >> 3), ref: func(a, _, f byte) (byte, byte) { return refInc(a, f) }}, true
+	case opcode < 0x40 && opcode&0x07 == 0x05 && opcode>>3 != 6:
+		return test{operand: -1, dest: int(opcode >> 3), ref: func(a, _, f byte) (byte, byte) { return refDec(a, f) }}, true
+	case opcode == 0x27:
+		return test{operand: -1, dest: 7, ref: func(a, _, f byte) (byte, byte) { return refDaa(a, f) }}, true
+	}
+	return test{}, false
+}
+
+// Generate builds a cartridge testing opcode with every combination of the
+// input values and F high nibbles
+func Generate(opcode byte) (*ROM, error) {
+	t, ok := lookup(opcode)
+	if !ok {
+		return nil, fmt.Errorf("opcode %02X is not supported, only 8-bit ALU, INC r, DEC r and DAA are", opcode)
+	}
+
+	rom := &ROM{Opcode: opcode, Image: make([]byte, 0x8000)}
+	code := []byte{
+		0xF3,             // DI
+		0x31, 0xFE, 0xFF, // LD SP,$FFFE
+	}
+	operands := values
+	if t.operand < 0 || t.operand == 7 {
+		operands = []byte{0} // The input is A itself
+	}
+	addr := resultStart
+	for _, a := range values {
+		for _, operand := range operands {
+			for f := 0x00; f <= 0xF0; f += 0x10 {
+				c := Case{A: a, Operand: operand, F: byte(f), Addr: addr}
+				if t.operand == 7 {
+					c.Operand = a
+				}
+				// The opcode works on the destination, which is A for
+				// everything but INC r and DEC r
+				c.WantA, c.WantF = t.ref(a, c.Operand, c.F)
+				rom.Cases = append(rom.Cases, c)
+				code = append(code, caseCode(opcode, t, c)...)
+				addr += 2
+			}
+		}
+	}
+	rom.End = codeStart + uint16(len(code))
+	code = append(code, 0x18, 0xFE) // JR $
+
+	if int(codeStart)+len(code) > len(rom.Image) {
+		return nil, fmt.Errorf("opcode %02X: %d bytes of code do not fit in 32KB", opcode, len(code))
+	}
+	copy(rom.Image[codeStart:], code)
+	writeHeader(rom.Image, fmt.Sprintf("OPCODE %02X", opcode))
+	return rom, nil
+}
+
+// caseCode loads the inputs, runs the opcode and stores the destination
+// register and F at c.Addr. Stores use absolute addresses so no register
+// pair is tied up as a pointer.
+func caseCode(opcode byte, t test, c Case) []byte {
+	in := c.A
+	if t.dest != 7 {
+		in = 0 // A is only a bystander
+	}
+	code := []byte{
+		0x16, in, // LD D,a
+		0x1E, c.F, // LD E,f
+		0xD5, // PUSH DE
+		0xF1, // POP AF
+	}
+	if t.dest != 7 {
+		code = append(code, 0x06|byte(t.dest)<<3, c.A) // LD r,a
+	}
+	if t.operand >= 0 && t.operand < 7 {
+		code = append(code, 0x06|byte(t.operand)<<3, c.Operand) // LD r,operand
+	}
+	code = append(code, opcode)
+	if t.operand == 8 {
+		code = append(code, c.Operand)
+	}
+	code = append(code, 0xF5) // PUSH AF
+	if t.dest != 7 {
+		code = append(code, 0x78|byte(t.dest)) // LD A,r
+	}
+	code = append(code, 0xEA, byte(c.Addr), byte(c.Addr>>8)) // LD (a16),A
+	code = append(code, 0xD1, 0x7B)                          // POP DE; LD A,E
+	return append(code, 0xEA, byte(c.Addr+1), byte((c.Addr+1)>>8))
+}
+
+// writeHeader fills in a cartridge header real hardware accepts: entry
+// point, logo, title, no MBC, 32KB ROM and valid checksums
+func writeHeader(image []byte, title string) {
+	copy(image[0x100:], []byte{0x00, 0xC3, 0x50, 0x01}) // NOP; JP $0150 (codeStart)
+	copy(image[0x104:], nintendoLogo[:])
+	copy(image[0x134:0x143], title)
+
+	var header byte
+	for _, b := range image[0x134:0x14D] {
+		header = header - b - 1
+	}
+	image[0x14D] = header
+
+	var global uint16
+	for i, b := range image {
+		if i != 0x14E && i != 0x14F {
+			global += uint16(b)
+		}
+	}
+	image[0x14E], image[0x14F] = byte(global>>8), byte(global)
+}
+
+// Check runs the cartridge on the core and returns every case whose result
+// differs from the reference
+func Check(rom *ROM) ([]Mismatch, error) {
+	probes := make([]uint16, 0, 2*len(rom.Cases))
+	for _, c := range rom.Cases {
+		probes = append(probes, c.Addr, c.Addr+1)
+	}
+	result := headless.Run(headless.Job{
+		ROM:    rom.Image,
+		Steps:  16*len(rom.Cases) + 16, // No case takes more than 12 instructions
+		Probes: probes,
+	})
+	switch {
+	case result.Fault != nil:
+		return nil, result.Fault
+	case result.Err != nil:
+		return nil, result.Err
+	case result.PC != rom.End:
+		return nil, fmt.Errorf("test stopped at %04X instead of %04X", result.PC, rom.End)
+	}
+
+	var mismatches []Mismatch
+	for _, c := range rom.Cases {
+		got := Mismatch{Case: c, GotA: result.Probes[c.Addr], GotF: result.Probes[c.Addr+1]}
+		if got.GotA != c.WantA || got.GotF != c.WantF {
+			mismatches = append(mismatches, got)
+		}
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,67 @@
+package testrom
+
+import "testing"
+
+// TestReferenceManualExamples checks the reference against the worked
+// examples of the Game Boy programming manual
+func TestReferenceManualExamples(t *testing.T) {
+	tests := []struct {
+		name         string
+		ref          func(a, b, f byte) (byte, byte)
+		a, b, f      byte
+		wantA, wantF byte
+	}{
+		{"ADD A,B", refALU[0], 0x3A, 0xC6, 0x00, 0x00, flagZ | flagH | flagC},
+		{"ADD A,(HL)", refALU[0], 0x3C, 0x12, 0x00, 0x4E, 0x00},
+		{"ADC A,E", refALU[1], 0xE1, 0x0F, flagC, 0xF1, flagH},
+		{"ADC A,d8", refALU[1], 0xE1, 0x3B, flagC, 0x1D, flagC},
+		{"SUB E", refALU[2], 0x3E, 0x3E, 0x00, 0x00, flagZ | flagN},
+		{"SUB d8", refALU[2], 0x3E, 0x0F, 0x00, 0x2F, flagN | flagH},
+		{"SUB (HL)", refALU[2], 0x3E, 0x40, 0x00, 0xFE, flagN | flagC},
+		{"SBC A,H", refALU[3], 0x3B, 0x2A, flagC, 0x10, flagN},
+		{"SBC A,d8", refALU[3], 0x3B, 0x3A, flagC, 0x00, flagZ | flagN},
+		{"SBC A,(HL)", refALU[3], 0x3B, 0x4F, flagC, 0xEB, flagN | flagH | flagC},
+		{"AND L", refALU[4], 0x5A, 0x3F, 0x00, 0x1A, flagH},
+		{"AND d8", refALU[4], 0x5A, 0x38, 0x00, 0x18, flagH},
+		{"AND (HL)", refALU[4], 0x5A, 0x00, 0x00, 0x00, flagZ | flagH},
+		{"XOR A", refALU[5], 0xFF, 0xFF, 0x00, 0x00, flagZ},
+		{"XOR d8", refALU[5], 0xFF, 0x0F, 0x00, 0xF0, 0x00},
+		{"OR A", refALU[6], 0x5A, 0x5A, 0x00, 0x5A, 0x00},
+		{"OR d8", refALU[6], 0x5A, 0x03, 0x00, 0x5B, 0x00},
+		{"CP B", refALU[7], 0x3C, 0x2F, 0x00, 0x3C, flagN | flagH},
+		{"CP d8", refALU[7], 0x3C, 0x3C, 0x00, 0x3C, flagZ | flagN},
+		{"CP (HL)", refALU[7], 0x3C, 0x40, 0x00, 0x3C, flagN | flagC},
+		{"INC A", func(a, _, f byte) (byte, byte) { return refInc(a, f) }, 0xFF, 0, 0x00, 0x00, flagZ | flagH},
+		{"DEC L", func(a, _, f byte) (byte, byte) { return refDec(a, f) }, 0x01, 0, 0x00, 0x00, flagZ | flagN},
+		{"DEC (HL)", func(a, _, f byte) (byte, byte) { return refDec(a, f) }, 0x00, 0, 0x00, 0xFF, flagN | flagH},
+		{"DAA after ADD", func(a, _, f byte) (byte, byte) { return refDaa(a, f) }, 0x7D, 0, 0x00, 0x83, 0x00},           // 45 + 38
+		{"DAA after SUB", func(a, _, f byte) (byte, byte) { return refDaa(a, f) }, 0x4B, 0, flagN | flagH, 0x45, flagN}, // 83 - 38
+	}
+	for _, tt := range tests {
+		gotA, gotF := tt.ref(tt.a, tt.b, tt.f)
+		if gotA != tt.wantA || gotF != tt.wantF {
+			t.Errorf("%s with A=%02X, %02X: got %02X/%02X, want %02X/%02X", tt.name, tt.a, tt.b, gotA, gotF, tt.wantA, tt.wantF)
+		}
+	}
+}
+
+// TestCoreMatchesReference runs every supported opcode's cartridge on the
+// core
+func TestCoreMatchesReference(t *testing.T) {
+	for opcode := 0; opcode < 0x100; opcode++ {
+		if _, ok := lookup(byte(opcode)); !ok {
+			continue
+		}
+		rom, err := Generate(byte(opcode))
+		if err != nil {
+			t.Fatalf("%02X: %v", opcode, err)
+		}
+		mismatches, err := Check(rom)
+		if err != nil {
+			t.Fatalf("%02X: %v", opcode, err)
+		}
+		for _, m := range mismatches {
+			t.Errorf("%02X: %v", opcode, m)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"clockworkgnome/memory"
+)
+
+// ChromeTracer records bus accesses in the Chrome trace event format, which
+// both chrome://tracing and the Perfetto UI can open
+type ChromeTracer struct {
+	SampleEvery int    // Record only every Nth matching access, 0 or 1 records all
+	Start, End  uint16 // Only record accesses within this inclusive range
+
+	w       *bufio.Writer
+	cycles  func() int
+	clockHz func() int // Turns cycles into microseconds
+	last    int        // Cycle count at the previous access
+	ts      float64    // Microseconds at the previous access
+	count   int
+	err     error
+}
+
+// NewChromeTracer writes a trace to w. cycles is polled for the timestamp of
+// every access, normally it returns the CPU cycle counter, and clockHz is the
+// rate it currently counts at, such as the ClockHz of CPU.Timing. The rate may
+// change mid-trace, as it does on a CGB speed switch; cycles counted before
+// the change keep the time they were recorded at.
+func NewChromeTracer(w io.Writer, cycles func() int, clockHz func() int) *ChromeTracer {
+	t := &ChromeTracer{
+		Start:   0x0000,
+		End:     0xFFFF,
+		w:       bufio.NewWriter(w),
+		cycles:  cycles,
+		clockHz: clockHz,
+		last:    cycles(),
+	}
+
+	// Name one track per access kind
+	fmt.Fprint(t.w, "[")
+	for i, kind := range []memory.AccessKind{memory.AccessFetch, memory.AccessRead, memory.AccessWrite} {
+		if i > 0 {
+			fmt.Fprint(t.w, ",")
+		}
+		fmt.Fprintf(t.w, "\n{\"name\":\"thread_name\",\"ph\":\"M\",\"pid\":1,\"tid\":%d,\"args\":{\"name\":\"%s\"}}", kind+1, kind)
+	}
+	return t
+}
+
+// Access implements memory.Tracer
+func (t *ChromeTracer) Access(kind memory.AccessKind, addr uint16, value byte) {
+	if t.err != nil {
+		return
+	}
+	now := t.cycles()
+	t.ts += float64(now-t.last) * 1e6 / float64(t.clockHz())
+	t.last = now
+	if addr < t.Start || addr > t.End {
+		return
+	}
+	t.count++
+	if t.SampleEvery > 1 && t.count%t.SampleEvery != 0 {
+		return
+	}
+
+	_, t.err = fmt.Fprintf(t.w,
+		",\n{\"name\":\"%s %04X\",\"ph\":\"i\",\"s\":\"t\",\"pid\":1,\"tid\":%d,\"ts\":%.3f,\"args\":{\"addr\":\"%04X\",\"value\":\"%02X\"}}",
+		kind, addr, kind+1, t.ts, addr, value)
+}
+
+// Close terminates the trace and flushes it, returning the first write error
+func (t *ChromeTracer) Close() error {
+	if t.err != nil {
+		return t.err
+	}
+	fmt.Fprint(t.w, "\n]\n")
+	return t.w.Flush()
+}
@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"clockworkgnome/memory"
+)
+
+func TestChromeTracerTimestamps(t *testing.T) {
+	for _, tt := range []struct {
+		clockHz int
+		want    float64
+	}{
+		{4194304, 1e6}, // DMG, one second
+		{4295454, 4194304 * 1e6 / 4295454},
+		{8388608, 5e5}, // CGB double speed
+	} {
+		var out bytes.Buffer
+		cycles := 0
+		tracer := NewChromeTracer(&out, func() int { return cycles }, func() int { return tt.clockHz })
+		cycles = 4194304
+		tracer.Access(memory.AccessWrite, 0xC000, 0x42)
+		if err := tracer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		events := decodeTrace(t, out.Bytes())
+		last := events[len(events)-1]
+		if last.Ph != "i" || last.Ts < tt.want-0.001 || last.Ts > tt.want+0.001 {
+			t.Errorf("%d Hz: event %+v, want ts %.3f", tt.clockHz, last, tt.want)
+		}
+	}
+}
+
+func TestChromeTracerSpeedSwitch(t *testing.T) {
+	// One second at normal speed, then a switch to double speed, where the
+	// cycle counter runs twice as fast for the next second
+	var out bytes.Buffer
+	cycles, clockHz := 0, 4194304
+	tracer := NewChromeTracer(&out, func() int { return cycles }, func() int { return clockHz })
+	cycles = 4194304
+	tracer.Access(memory.AccessFetch, 0x0150, 0x10) // STOP
+	clockHz = 8388608
+	cycles += 8388608
+	tracer.Access(memory.AccessFetch, 0x0152, 0x00)
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := decodeTrace(t, out.Bytes())
+	for i, want := range []float64{1e6, 2e6} {
+		got := events[len(events)-2+i].Ts
+		if got < want-0.001 || got > want+0.001 {
+			t.Errorf("access %d: ts %.3f, want %.3f", i, got, want)
+		}
+	}
+}
+
+type traceEvent struct {
+	Ph string  `json:"ph"`
+	Ts float64 `json:"ts"`
+}
+
+func decodeTrace(t *testing.T, data []byte) []traceEvent {
+	t.Helper()
+	var events []traceEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatalf("trace is not valid JSON: %v\n%s", err, data)
+	}
+	return events
+}